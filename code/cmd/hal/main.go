@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	controller "github.com/r4stl1n/micro-hal/code/internal/controller-node/managers"
+	joints "github.com/r4stl1n/micro-hal/code/internal/joints-node/managers"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	logrus.SetFormatter(&logrus.TextFormatter{
+		DisableColors: false,
+		FullTimestamp: true,
+	})
+
+	logrus.SetLevel(logrus.InfoLevel)
+}
+
+// nodeNatsConfig copies the nats config for one of the nodes. The nodes are told apart by
+// the sender name of their messages, so a name set with NATS_NODE_NAME gets the node
+// appended, without one each node uses its default name
+func nodeNatsConfig(natsConfig structs.NatsConfig, node string) structs.NatsConfig {
+	natsConfig.Servers = append([]string{}, natsConfig.Servers...)
+
+	if natsConfig.Name != "" {
+		natsConfig.Name = natsConfig.Name + "-" + node
+	}
+
+	return natsConfig
+}
+
+// main runs an embedded nats server together with the controller-node and the
+// joints-node in a single process
+func main() {
+	jointsConfig := new(structs.JointsConfig).Defaults()
+
+	flag.StringVar(&jointsConfig.Backend, "backend", jointsConfig.Backend, "servo backend to use (hardware or sim)")
+	flag.Parse()
+
+	natsConfig, err := new(structs.NatsConfig).Defaults()
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	natsServer := new(mq.Server).Init(*natsConfig)
+
+	if err := natsServer.Start(); err != nil {
+		logrus.Fatal(err)
+	}
+
+	defer natsServer.Shutdown()
+
+	logrus.Infof("embedded nats server started on %s", natsConfig.Host)
+
+	jointsManager, err := new(joints.JointsManager).Init(nodeNatsConfig(*natsConfig, "joints"), *jointsConfig)
+
+	if err != nil {
+		natsServer.Shutdown()
+		logrus.Fatal(err)
+	}
+
+	nodeManager, err := new(controller.NodeManager).Init(nodeNatsConfig(*natsConfig, "controller"),
+		*new(structs.ControllerConfig).Defaults())
+
+	if err != nil {
+		natsServer.Shutdown()
+		logrus.Fatal(err)
+	}
+
+	errorChannel := make(chan error, 2)
+
+	go func() {
+		errorChannel <- jointsManager.Process()
+	}()
+
+	go func() {
+		errorChannel <- nodeManager.Process()
+	}()
+
+	logrus.Info("hal started")
+
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
+
+	running := 2
+
+	select {
+	case serviceError := <-errorChannel:
+		running = running - 1
+
+		if serviceError != nil {
+			logrus.Error(serviceError)
+		}
+
+	case <-signalChannel:
+		logrus.Info("shutting down")
+	}
+
+	nodeManager.Stop()
+	jointsManager.Stop()
+
+	// Wait for both nodes to finish so the servo state is saved before the server stops
+	for ; running > 0; running-- {
+		if serviceError := <-errorChannel; serviceError != nil {
+			logrus.Error(serviceError)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/r4stl1n/micro-hal/code/internal/joints-node/managers"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
 	"github.com/sirupsen/logrus"
 	"os"
 	"os/signal"
@@ -11,14 +13,18 @@ import (
 
 // setupCloseHandler creates a 'listener' on a new goroutine which will notify the
 // program if it receives an interrupt from the OS. We then handle this by calling
-// our clean-up procedure and exiting the program.
-func setupCloseHandler() {
-	c := make(chan os.Signal)
+// stop so the node can clean up and return from Process, a second interrupt exits
+// the program straight away.
+func setupCloseHandler(stop func()) {
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
 		fmt.Println("\r- Ctrl+C pressed in Terminal")
-		os.Exit(0)
+		stop()
+
+		<-c
+		os.Exit(1)
 	}()
 }
 
@@ -32,14 +38,25 @@ func init() {
 }
 
 func main() {
-	setupCloseHandler()
+	config := new(structs.JointsConfig).Defaults()
+
+	flag.StringVar(&config.Backend, "backend", config.Backend, "servo backend to use (hardware or sim)")
+	flag.Parse()
 
-	serviceManager, err := new(managers.JointsManager).Init()
+	natsConfig, err := new(structs.NatsConfig).Defaults()
 
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
+	serviceManager, err := new(managers.JointsManager).Init(*natsConfig, *config)
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	setupCloseHandler(serviceManager.Stop)
+
 	logrus.Info("joints node started")
 
 	serviceError := serviceManager.Process()
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+// CommandWatchdog trips when the robot is walking and no velocity command has been fed
+// within the timeout. After a trip it ramps the velocities to zero and the pose to the
+// nominal stance over the ramp time
+type CommandWatchdog struct {
+	timeout  time.Duration
+	rampTime time.Duration
+
+	lastCommand time.Time
+
+	ramping        bool
+	rampStart      time.Time
+	rampPose       bool
+	fromVelocities cstructs.Velocities
+	fromPose       cstructs.Pose
+}
+
+func (commandWatchdog *CommandWatchdog) Init(timeout time.Duration, rampTime time.Duration, currentTime time.Time) *CommandWatchdog {
+	*commandWatchdog = CommandWatchdog{
+		timeout:     timeout,
+		rampTime:    rampTime,
+		lastCommand: currentTime,
+	}
+
+	return commandWatchdog
+}
+
+// Feed records a velocity command and cancels a running ramp
+func (commandWatchdog *CommandWatchdog) Feed(currentTime time.Time) {
+	commandWatchdog.lastCommand = currentTime
+	commandWatchdog.ramping = false
+}
+
+// CancelPoseRamp stops settling the pose, used when a new pose is commanded during a ramp
+func (commandWatchdog *CommandWatchdog) CancelPoseRamp() {
+	commandWatchdog.rampPose = false
+}
+
+// Check trips the watchdog when the velocities are not zero and the last command is older
+// than the timeout. On a trip the ramp starts from the given velocities and pose and
+// the trip event is returned
+func (commandWatchdog *CommandWatchdog) Check(currentTime time.Time, velocities cstructs.Velocities, pose cstructs.Pose) *messages.WatchdogTrip {
+	if commandWatchdog.timeout <= 0 || velocities == (cstructs.Velocities{}) {
+		return nil
+	}
+
+	since := currentTime.Sub(commandWatchdog.lastCommand)
+	if since <= commandWatchdog.timeout {
+		return nil
+	}
+
+	commandWatchdog.ramping = true
+	commandWatchdog.rampPose = true
+	commandWatchdog.rampStart = currentTime
+	commandWatchdog.fromVelocities = velocities
+	commandWatchdog.fromPose = pose
+
+	trip := new(messages.WatchdogTrip).Init()
+	trip.Timeout = float32(commandWatchdog.timeout.Seconds())
+	trip.Since = float32(since.Seconds())
+	trip.LinearX = velocities.Linear.X()
+	trip.LinearY = velocities.Linear.Y()
+	trip.AngularZ = velocities.Angular.Z()
+
+	return trip
+}
+
+// Ramp returns the velocities and pose to apply while a ramp is running, otherwise the
+// given values are returned unchanged
+func (commandWatchdog *CommandWatchdog) Ramp(currentTime time.Time, velocities cstructs.Velocities, pose cstructs.Pose) (cstructs.Velocities, cstructs.Pose) {
+	if !commandWatchdog.ramping {
+		return velocities, pose
+	}
+
+	fraction := float32(1.0)
+	if commandWatchdog.rampTime > 0 {
+		fraction = float32(currentTime.Sub(commandWatchdog.rampStart).Seconds() / commandWatchdog.rampTime.Seconds())
+	}
+
+	if fraction >= 1.0 {
+		commandWatchdog.ramping = false
+		return velocities, pose
+	}
+
+	velocities = cstructs.Velocities{
+		Linear:  lerpVec3(commandWatchdog.fromVelocities.Linear, velocities.Linear, fraction),
+		Angular: lerpVec3(commandWatchdog.fromVelocities.Angular, velocities.Angular, fraction),
+	}
+
+	if commandWatchdog.rampPose {
+		pose = cstructs.Pose{
+			Position:    lerpVec3(commandWatchdog.fromPose.Position, pose.Position, fraction),
+			Orientation: lerpVec3(commandWatchdog.fromPose.Orientation, pose.Orientation, fraction),
+		}
+	}
+
+	return velocities, pose
+}
+
+func lerpVec3(from hmath.Vec3, to hmath.Vec3, fraction float32) hmath.Vec3 {
+	return from.Add(to.Sub(from).MulF(fraction))
+}
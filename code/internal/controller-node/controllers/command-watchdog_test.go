@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	math "github.com/chewxy/math32"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+func TestCommandWatchdogTrips(t *testing.T) {
+	start := time.Now()
+	walking := cstructs.Velocities{Linear: hmath.Vec3{0.2, 0.1, 0}, Angular: hmath.Vec3{0, 0, 0.5}}
+
+	tests := []struct {
+		name       string
+		timeout    time.Duration
+		velocities cstructs.Velocities
+		after      time.Duration
+		trips      bool
+	}{
+		{"fresh command", 500 * time.Millisecond, walking, 400 * time.Millisecond, false},
+		{"at the timeout", 500 * time.Millisecond, walking, 500 * time.Millisecond, false},
+		{"stale command", 500 * time.Millisecond, walking, 600 * time.Millisecond, true},
+		{"standing still", 500 * time.Millisecond, cstructs.Velocities{}, time.Hour, false},
+		{"disabled", 0, walking, time.Hour, false},
+	}
+
+	for _, test := range tests {
+		commandWatchdog := new(CommandWatchdog).Init(test.timeout, 200*time.Millisecond, start)
+
+		trip := commandWatchdog.Check(start.Add(test.after), test.velocities, cstructs.Pose{})
+		if (trip != nil) != test.trips {
+			t.Errorf("%s: tripped %t, expected %t", test.name, trip != nil, test.trips)
+			continue
+		}
+
+		if trip == nil {
+			continue
+		}
+
+		if trip.Timeout != 0.5 || math.Abs(trip.Since-0.6) > 1e-6 || trip.LinearX != 0.2 || trip.LinearY != 0.1 ||
+			trip.AngularZ != 0.5 {
+			t.Errorf("%s: trip event %+v", test.name, trip)
+		}
+	}
+}
+
+func TestCommandWatchdogRamp(t *testing.T) {
+	start := time.Now()
+	walking := cstructs.Velocities{Linear: hmath.Vec3{0.2, 0, 0}}
+	leaning := cstructs.Pose{Position: hmath.Vec3{0.02, 0, 0.18}, Orientation: hmath.Vec3{0, 0.2, 0}}
+	nominal := cstructs.Pose{Position: hmath.Vec3{0, 0, 0.2}}
+
+	commandWatchdog := new(CommandWatchdog).Init(500*time.Millisecond, 200*time.Millisecond, start)
+
+	// Without a trip the values are passed through
+	if velocities, pose := commandWatchdog.Ramp(start, walking, leaning); velocities != walking || pose != leaning {
+		t.Fatalf("ramp without a trip returned %+v and %+v", velocities, pose)
+	}
+
+	tripTime := start.Add(time.Second)
+
+	if commandWatchdog.Check(tripTime, walking, leaning) == nil {
+		t.Fatal("watchdog did not trip")
+	}
+
+	// The controller drops the requested values on a trip, the ramp blends them in
+	tests := []struct {
+		after   time.Duration
+		linearX float32
+		pitch   float32
+		ramping bool
+	}{
+		{0, 0.2, 0.2, true},
+		{50 * time.Millisecond, 0.15, 0.15, true},
+		{100 * time.Millisecond, 0.1, 0.1, true},
+		{200 * time.Millisecond, 0, 0, false},
+		{300 * time.Millisecond, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		velocities, pose := commandWatchdog.Ramp(tripTime.Add(test.after), cstructs.Velocities{}, nominal)
+
+		if math.Abs(velocities.Linear.X()-test.linearX) > 1e-5 || math.Abs(pose.Orientation.Y()-test.pitch) > 1e-5 {
+			t.Errorf("after %s: ramped to %+v and %+v, expected linear x %f and pitch %f", test.after, velocities,
+				pose, test.linearX, test.pitch)
+		}
+
+		if commandWatchdog.ramping != test.ramping {
+			t.Errorf("after %s: ramping %t, expected %t", test.after, commandWatchdog.ramping, test.ramping)
+		}
+	}
+}
+
+func TestCommandWatchdogRecovers(t *testing.T) {
+	start := time.Now()
+	walking := cstructs.Velocities{Linear: hmath.Vec3{0.2, 0, 0}}
+	leaning := cstructs.Pose{Position: hmath.Vec3{0, 0, 0.18}}
+	nominal := cstructs.Pose{Position: hmath.Vec3{0, 0, 0.2}}
+
+	commandWatchdog := new(CommandWatchdog).Init(500*time.Millisecond, 200*time.Millisecond, start)
+	tripTime := start.Add(time.Second)
+
+	if commandWatchdog.Check(tripTime, walking, leaning) == nil {
+		t.Fatal("watchdog did not trip")
+	}
+
+	// A new pose stops settling the pose but the velocities keep ramping down
+	commandWatchdog.CancelPoseRamp()
+
+	velocities, pose := commandWatchdog.Ramp(tripTime.Add(100*time.Millisecond), cstructs.Velocities{}, nominal)
+	if math.Abs(velocities.Linear.X()-0.1) > 1e-5 || pose != nominal {
+		t.Fatalf("ramp after a new pose returned %+v and %+v", velocities, pose)
+	}
+
+	// A new velocity command cancels the ramp and restarts the timeout
+	feedTime := tripTime.Add(150 * time.Millisecond)
+	commandWatchdog.Feed(feedTime)
+
+	if velocities, _ := commandWatchdog.Ramp(feedTime, walking, nominal); velocities != walking {
+		t.Fatalf("ramp after a new command returned %+v, expected %+v", velocities, walking)
+	}
+
+	if trip := commandWatchdog.Check(feedTime.Add(500*time.Millisecond), walking, nominal); trip != nil {
+		t.Fatalf("watchdog tripped within the timeout of the new command: %+v", trip)
+	}
+
+	if trip := commandWatchdog.Check(feedTime.Add(600*time.Millisecond), walking, nominal); trip == nil {
+		t.Fatal("watchdog did not trip again after the new command went stale")
+	}
+}
+
+// walk sets the velocities and feeds the watchdog at the tick time instead of the wall clock
+func walk(t *testing.T, quadController *QuadController, velocities cstructs.Velocities, currentTime time.Time) {
+	t.Helper()
+
+	if _, err := quadController.SetVelocities(velocities); err != nil {
+		t.Fatal(err)
+	}
+
+	quadController.watchdog.Feed(currentTime)
+}
+
+func TestQuadControllerWatchdogStopsWalking(t *testing.T) {
+	config := testConfig()
+	quadController := newController(config)
+	currentTime := standUp(t, quadController)
+
+	walking := cstructs.Velocities{Linear: hmath.Vec3{0.1, 0, 0}}
+	walk(t, quadController, walking, currentTime)
+
+	pose := new(messages.Pose).Init()
+	pose.Pitch = 0.1
+
+	if err := quadController.SetPose(pose); err != nil {
+		t.Fatal(err)
+	}
+
+	currentTime = currentTime.Add(tickPeriod)
+	quadController.Tick(currentTime)
+
+	if state := RobotState(quadController.State().State); state != StateWalking {
+		t.Fatalf("controller is %s, expected %s", state, StateWalking)
+	}
+
+	if applied := quadController.AppliedVelocities(); applied != walking {
+		t.Fatalf("controller applied %+v, expected %+v", applied, walking)
+	}
+
+	// The commands go stale, the ramp to a stop in the nominal pose starts from the walking
+	// velocities on the tick that trips the watchdog
+	currentTime = currentTime.Add(config.CommandTimeout)
+	quadController.Tick(currentTime)
+
+	if applied := quadController.AppliedVelocities(); applied != walking {
+		t.Fatalf("controller applied %+v on the trip, expected %+v", applied, walking)
+	}
+
+	if applied := quadController.Pose(); applied.Pitch != 0 {
+		t.Fatalf("controller pose %+v after the trip, expected the nominal pose", applied)
+	}
+
+	currentTime = currentTime.Add(tickPeriod)
+	quadController.Tick(currentTime)
+
+	if applied := quadController.AppliedVelocities(); applied.Linear.X() <= 0 || applied.Linear.X() >= walking.Linear.X() {
+		t.Fatalf("controller applied %+v during the ramp, expected less than %+v", applied, walking)
+	}
+
+	// The robot stands once the ramp has reached zero
+	currentTime, _ = tickUntil(t, quadController, StateStanding, currentTime)
+
+	if applied := quadController.AppliedVelocities(); applied != (cstructs.Velocities{}) {
+		t.Fatalf("controller applied %+v after the ramp, expected zero", applied)
+	}
+
+	// A new command recovers from the trip and walks again
+	walk(t, quadController, walking, currentTime)
+
+	currentTime = currentTime.Add(tickPeriod)
+	quadController.Tick(currentTime)
+
+	if state := RobotState(quadController.State().State); state != StateWalking {
+		t.Fatalf("controller is %s after the new command, expected %s", state, StateWalking)
+	}
+
+	if applied := quadController.AppliedVelocities(); applied != walking {
+		t.Fatalf("controller applied %+v after the new command, expected %+v", applied, walking)
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/champ"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+)
+
+// JointTrajectory moves the feet from their current positions to the target positions
+// over a fixed duration. The feet follow straight lines eased with a minimum jerk
+// profile and the joints of every sample are computed through the inverse kinematics
+type JointTrajectory struct {
+	kinematics *champ.Kinematics
+
+	from      [4]cstructs.Transformation
+	to        [4]cstructs.Transformation
+	startTime time.Time
+	duration  time.Duration
+}
+
+func (jointTrajectory *JointTrajectory) Init(kinematics *champ.Kinematics, jointPositions [12]float32,
+	footPositions [4]cstructs.Transformation, startTime time.Time, duration time.Duration) *JointTrajectory {
+
+	*jointTrajectory = JointTrajectory{
+		kinematics: kinematics,
+		from:       kinematics.Forward(jointPositions),
+		to:         footPositions,
+		startTime:  startTime,
+		duration:   duration,
+	}
+
+	return jointTrajectory
+}
+
+// Progress returns how far along the trajectory is between 0 and 1
+func (jointTrajectory *JointTrajectory) Progress(currentTime time.Time) float32 {
+	if jointTrajectory.duration <= 0 {
+		return 1.0
+	}
+
+	progress := float32(currentTime.Sub(jointTrajectory.startTime).Seconds() / jointTrajectory.duration.Seconds())
+
+	if progress < 0.0 {
+		return 0.0
+	}
+
+	if progress > 1.0 {
+		return 1.0
+	}
+
+	return progress
+}
+
+// Sample returns the joints at the given time and whether the end of the trajectory
+// has been reached. Unreachable samples keep the previous joints
+func (jointTrajectory *JointTrajectory) Sample(jointPositions [12]float32, currentTime time.Time) ([12]float32, bool) {
+	progress := jointTrajectory.Progress(currentTime)
+
+	// Minimum jerk easing, the feet start and stop with zero velocity and acceleration
+	fraction := progress * progress * progress * (10.0 - (15.0 * progress) + (6.0 * progress * progress))
+
+	footPositions := jointTrajectory.to
+
+	for i := 0; i < 4; i++ {
+		footPositions[i].Point = lerpVec3(jointTrajectory.from[i].Point, jointTrajectory.to[i].Point, fraction)
+	}
+
+	return jointTrajectory.kinematics.Inverse(jointPositions, footPositions), progress >= 1.0
+}
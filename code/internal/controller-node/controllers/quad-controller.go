@@ -0,0 +1,413 @@
+package controllers
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/champ"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cbase"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+	"github.com/sirupsen/logrus"
+)
+
+const statsReportInterval = 10 * time.Second
+
+// LoopStats stores the timing statistics of the control loop
+type LoopStats struct {
+	Ticks      uint64
+	Overruns   uint64
+	MaxJitter  time.Duration
+	MeanJitter time.Duration
+	MaxRunTime time.Duration
+}
+
+// QuadController runs the champ controllers at a fixed rate using the latest
+// commanded pose and velocity and publishes the resulting joints every tick. Poses and
+// velocities are only applied while standing or walking, the other states are entered
+// through joint trajectories requested with RequestState
+type QuadController struct {
+	nats   *mq.Nats
+	config structs.ControllerConfig
+
+	quadBase       *cbase.QuadBase
+	legController  *champ.LegController
+	bodyController *champ.BodyController
+	kinematics     *champ.Kinematics
+	odometry       *champ.Odometry
+	watchdog       *CommandWatchdog
+
+	mutex               sync.Mutex
+	requestedPose       cstructs.Pose
+	requestedVelocities cstructs.Velocities
+	requestedGait       string
+	appliedVelocities   cstructs.Velocities
+	odometryVelocities  cstructs.Velocities
+
+	state      RobotState
+	target     RobotState
+	trajectory *JointTrajectory
+	settled    RobotState // state entered once the trajectory ends
+	published  RobotState // last state published on the state channel
+
+	footPositions  [4]cstructs.Transformation
+	jointPositions [12]float32
+
+	stats       LoopStats
+	jitterTotal time.Duration
+}
+
+func (quadController *QuadController) Init(nats *mq.Nats, quadBase *cbase.QuadBase, config structs.ControllerConfig) *QuadController {
+	currentTime := time.Now()
+
+	*quadController = QuadController{
+		nats:           nats,
+		config:         config,
+		quadBase:       quadBase,
+		legController:  new(champ.LegController).Init(quadBase, currentTime),
+		bodyController: new(champ.BodyController).Init(quadBase),
+		kinematics:     new(champ.Kinematics).Init(quadBase),
+		odometry:       new(champ.Odometry).Init(quadBase, currentTime),
+		watchdog:       new(CommandWatchdog).Init(config.CommandTimeout, config.StopRampTime, currentTime),
+		state:          StatePoweredOff,
+		target:         StatePoweredOff,
+	}
+
+	quadController.requestedPose = quadController.nominalPose()
+
+	return quadController
+}
+
+// SetPose sets the requested body pose. The z value of the pose is treated as an
+// offset from the nominal height of the gait config
+func (quadController *QuadController) SetPose(pose *messages.Pose) error {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	if stateError := quadController.allowMotion("pose"); stateError != nil {
+		return stateError
+	}
+
+	quadController.requestedPose = cstructs.Pose{
+		Position: hmath.Vec3{
+			pose.X,
+			pose.Y,
+			quadController.quadBase.GaitConfig().NominalHeight + pose.Z,
+		},
+		Orientation: hmath.Vec3{pose.Roll, pose.Pitch, pose.Yaw},
+	}
+
+	quadController.watchdog.CancelPoseRamp()
+
+	return nil
+}
+
+// Pose returns the body pose applied by the controller in the same form as SetPose
+func (quadController *QuadController) Pose() *messages.Pose {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	pose := new(messages.Pose).Init()
+	pose.X = quadController.requestedPose.Position.X()
+	pose.Y = quadController.requestedPose.Position.Y()
+	pose.Z = quadController.requestedPose.Position.Z() - quadController.quadBase.GaitConfig().NominalHeight
+	pose.Roll = quadController.requestedPose.Orientation.X()
+	pose.Pitch = quadController.requestedPose.Orientation.Y()
+	pose.Yaw = quadController.requestedPose.Orientation.Z()
+
+	return pose
+}
+
+// SetVelocities clamps the velocities against the gait config limits, sets them as the
+// requested body velocities and returns the clamped values. Every call feeds the
+// command watchdog. Velocities other than zero start walking and are only accepted
+// while standing or walking
+func (quadController *QuadController) SetVelocities(velocities cstructs.Velocities) (cstructs.Velocities, error) {
+	gaitConfig := quadController.quadBase.GaitConfig()
+
+	velocities.Linear.SetX(clamp(velocities.Linear.X(), gaitConfig.MaxLinearVelocity.X()))
+	velocities.Linear.SetY(clamp(velocities.Linear.Y(), gaitConfig.MaxLinearVelocity.Y()))
+	velocities.Angular.SetZ(clamp(velocities.Angular.Z(), gaitConfig.MaxAngularVelocity))
+
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	if quadController.state == StateEStopped {
+		return cstructs.Velocities{}, mq.ErrEStopEngaged
+	}
+
+	if velocities != (cstructs.Velocities{}) {
+		if stateError := quadController.allowMotion("velocities"); stateError != nil {
+			return cstructs.Velocities{}, stateError
+		}
+
+		quadController.setState(StateWalking)
+	}
+
+	quadController.requestedVelocities = velocities
+	quadController.watchdog.Feed(time.Now())
+
+	return velocities, nil
+}
+
+// Halt drops the requested velocities so the legs return to their stance, the pose
+// is kept
+func (quadController *QuadController) Halt(reason string) {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	if quadController.requestedVelocities != (cstructs.Velocities{}) {
+		logrus.Warnf("halting: %s", reason)
+	}
+
+	quadController.requestedVelocities = cstructs.Velocities{}
+}
+
+// EStop drops the requested velocities and enters the e-stopped state, no joints are
+// published and commands are refused until Resume is called
+func (quadController *QuadController) EStop(reason string) {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	logrus.Errorf("emergency stop, control loop stopped: %s", reason)
+
+	quadController.requestedVelocities = cstructs.Velocities{}
+	quadController.trajectory = nil
+	quadController.target = StatePoweredOff
+	quadController.setState(StateEStopped)
+}
+
+// Resume leaves the e-stopped state after an emergency stop. The servos were turned
+// off so the controller starts powered off and has to be brought up again
+func (quadController *QuadController) Resume() {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	logrus.Info("control loop resumed")
+
+	quadController.requestedVelocities = cstructs.Velocities{}
+	quadController.requestedPose = quadController.nominalPose()
+	quadController.watchdog.Feed(time.Now())
+	quadController.setState(StatePoweredOff)
+}
+
+// SetGait requests a switch to the named gait, the switch happens on the next tick
+// and is blended in at the following stride boundary
+func (quadController *QuadController) SetGait(name string) error {
+	if _, err := champ.GaitByName(name); err != nil {
+		return err
+	}
+
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	if quadController.state == StateEStopped {
+		return mq.ErrEStopEngaged
+	}
+
+	quadController.requestedGait = name
+
+	return nil
+}
+
+// AppliedVelocities returns the velocities used by the leg controller on the last tick
+func (quadController *QuadController) AppliedVelocities() cstructs.Velocities {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	return quadController.appliedVelocities
+}
+
+// OdometryVelocities returns the velocities estimated by odometry on the last tick
+func (quadController *QuadController) OdometryVelocities() cstructs.Velocities {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	return quadController.odometryVelocities
+}
+
+// Stats returns the timing statistics of the control loop
+func (quadController *QuadController) Stats() LoopStats {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	return quadController.stats
+}
+
+// Tick runs a single iteration of the state machine and the controllers and returns the
+// new joint positions, nil is returned when no joints should be published
+func (quadController *QuadController) Tick(currentTime time.Time) *messages.Joints {
+	quadController.mutex.Lock()
+	publish, controlled := quadController.stepState(currentTime)
+	robotState := quadController.stateMessage(currentTime)
+	stateChanged := quadController.state != quadController.published
+	quadController.published = quadController.state
+	quadController.mutex.Unlock()
+
+	if stateChanged {
+		quadController.publishState(robotState)
+	}
+
+	if !publish {
+		return nil
+	}
+
+	if controlled {
+		return quadController.tickControllers(currentTime)
+	}
+
+	quadController.quadBase.UpdateJointPositions(quadController.jointPositions[:])
+
+	return JointsFromPositions(quadController.jointPositions)
+}
+
+// tickControllers runs the champ controllers with the requested pose and velocities
+func (quadController *QuadController) tickControllers(currentTime time.Time) *messages.Joints {
+	quadController.mutex.Lock()
+	trip := quadController.watchdog.Check(currentTime, quadController.requestedVelocities, quadController.requestedPose)
+	if trip != nil {
+		quadController.requestedVelocities = cstructs.Velocities{}
+		quadController.requestedPose = quadController.nominalPose()
+	}
+
+	velocities, pose := quadController.watchdog.Ramp(currentTime, quadController.requestedVelocities, quadController.requestedPose)
+	requestedGait := quadController.requestedGait
+	quadController.requestedGait = ""
+	quadController.mutex.Unlock()
+
+	if trip != nil {
+		quadController.publishTrip(trip)
+	}
+
+	if requestedGait != "" {
+		if gaitError := quadController.legController.SetGait(requestedGait); gaitError != nil {
+			logrus.Error(gaitError)
+		} else {
+			logrus.Infof("switching to gait %s", requestedGait)
+		}
+	}
+
+	quadController.footPositions = quadController.bodyController.PoseCommand(quadController.footPositions, &pose)
+	quadController.footPositions, velocities = quadController.legController.VelocityCommand(quadController.footPositions, velocities, currentTime)
+	quadController.jointPositions = quadController.kinematics.Inverse(quadController.jointPositions, quadController.footPositions)
+
+	quadController.quadBase.UpdateJointPositions(quadController.jointPositions[:])
+
+	for i := 0; i < 4; i++ {
+		quadController.quadBase.Legs[i].SetInContact(quadController.quadBase.Legs[i].IsInGaitPhase())
+	}
+
+	odometryVelocities := quadController.odometry.GetVelocities(cstructs.Velocities{}, currentTime)
+
+	quadController.mutex.Lock()
+	quadController.appliedVelocities = velocities
+	quadController.odometryVelocities = odometryVelocities
+	quadController.mutex.Unlock()
+
+	return JointsFromPositions(quadController.jointPositions)
+}
+
+// Run ticks the controllers at the configured rate until the stop channel is closed
+func (quadController *QuadController) Run(stop <-chan struct{}) {
+	period := time.Duration(float32(time.Second) / quadController.config.LoopRate)
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	report := time.NewTicker(statsReportInterval)
+	defer report.Stop()
+
+	logrus.Infof("control loop started at %.1f Hz", quadController.config.LoopRate)
+
+	lastTick := time.Now()
+
+	for {
+		select {
+		case <-stop:
+			logrus.Info("control loop stopped")
+			return
+
+		case tickTime := <-ticker.C:
+			if joints := quadController.Tick(tickTime); joints != nil {
+				publishError := quadController.nats.Publish(consts.MQJointSetChannel, joints)
+				if publishError != nil && !errors.Is(publishError, mq.ErrDisconnected) {
+					logrus.Error(publishError)
+				}
+			}
+
+			quadController.recordTick(period, tickTime.Sub(lastTick), time.Since(tickTime))
+			lastTick = tickTime
+
+		case <-report.C:
+			stats := quadController.Stats()
+			logrus.Infof("control loop ticks: %d, overruns: %d, mean jitter: %s, max jitter: %s, max run time: %s",
+				stats.Ticks, stats.Overruns, stats.MeanJitter, stats.MaxJitter, stats.MaxRunTime)
+		}
+	}
+}
+
+func (quadController *QuadController) publishTrip(trip *messages.WatchdogTrip) {
+	logrus.Warnf("no velocity command for %.2fs, stopping and returning to the nominal stance", trip.Since)
+
+	publishError := quadController.nats.Publish(consts.MQWatchdogChannel, trip)
+	if publishError != nil && !errors.Is(publishError, mq.ErrDisconnected) {
+		logrus.Error(publishError)
+	}
+}
+
+func (quadController *QuadController) recordTick(period time.Duration, interval time.Duration, runTime time.Duration) {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	jitter := interval - period
+	if jitter < 0 {
+		jitter = -jitter
+	}
+
+	quadController.stats.Ticks = quadController.stats.Ticks + 1
+	quadController.jitterTotal = quadController.jitterTotal + jitter
+	quadController.stats.MeanJitter = quadController.jitterTotal / time.Duration(quadController.stats.Ticks)
+
+	if jitter > quadController.stats.MaxJitter {
+		quadController.stats.MaxJitter = jitter
+	}
+
+	if runTime > quadController.stats.MaxRunTime {
+		quadController.stats.MaxRunTime = runTime
+	}
+
+	if runTime > period {
+		quadController.stats.Overruns = quadController.stats.Overruns + 1
+		logrus.Warnf("control loop overrun, tick took %s with a period of %s", runTime, period)
+	}
+}
+
+func clamp(value float32, limit float32) float32 {
+	if value < -limit {
+		return -limit
+	}
+
+	if value > limit {
+		return limit
+	}
+
+	return value
+}
+
+// JointsFromPositions converts the kinematics joint array into a joints message
+func JointsFromPositions(jointPositions [12]float32) *messages.Joints {
+	joints := new(messages.Joints).Init()
+
+	legs := [4]*hmath.Vec3{&joints.LeftFront, &joints.RightFront, &joints.LeftBack, &joints.RightBack}
+
+	for i := 0; i < 4; i++ {
+		legs[i].SetXYZ(jointPositions[i*3], jointPositions[(i*3)+1], jointPositions[(i*3)+2])
+	}
+
+	return joints
+}
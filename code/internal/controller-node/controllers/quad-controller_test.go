@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	math "github.com/chewxy/math32"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cbase"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+const tickPeriod = 10 * time.Millisecond
+
+func testConfig() structs.ControllerConfig {
+	return structs.ControllerConfig{
+		LoopRate:       100,
+		CommandTimeout: 500 * time.Millisecond,
+		StopRampTime:   200 * time.Millisecond,
+		TransitionTime: 300 * time.Millisecond,
+	}
+}
+
+// newController returns a controller with the built in geometry that is not connected to
+// nats, its publishes fail with mq.ErrDisconnected and are ignored
+func newController(config structs.ControllerConfig) *QuadController {
+	quadBase := new(cbase.QuadBase).Init(*new(cstructs.GaitConfig).Defaults())
+	quadBase.SetGeometry(*new(cstructs.QuadGeometry).Defaults())
+
+	return new(QuadController).Init(new(mq.Nats).Init(structs.NatsConfig{}), quadBase, config)
+}
+
+// tickUntil ticks the controller until it reaches the state and returns the time of the
+// last tick and the joints it published
+func tickUntil(t *testing.T, quadController *QuadController, state RobotState, currentTime time.Time) (time.Time, *messages.Joints) {
+	t.Helper()
+
+	var joints *messages.Joints
+
+	for i := 0; i < 1000; i++ {
+		currentTime = currentTime.Add(tickPeriod)
+		joints = quadController.Tick(currentTime)
+
+		if RobotState(quadController.State().State) == state {
+			return currentTime, joints
+		}
+	}
+
+	t.Fatalf("controller did not reach %s, it is %s", state, quadController.State().State)
+
+	return currentTime, nil
+}
+
+// standUp brings a powered off controller to the standing state
+func standUp(t *testing.T, quadController *QuadController) time.Time {
+	t.Helper()
+
+	if err := quadController.RequestState(StateStanding); err != nil {
+		t.Fatal(err)
+	}
+
+	currentTime, _ := tickUntil(t, quadController, StateStanding, time.Now())
+
+	return currentTime
+}
+
+// poseJoints returns the joints the controller should publish for the pose
+func poseJoints(quadController *QuadController, pose cstructs.Pose) *messages.Joints {
+	var jointPositions [12]float32
+
+	return JointsFromPositions(quadController.kinematics.Inverse(jointPositions, quadController.footPositionsFor(pose)))
+}
+
+func jointsClose(a *messages.Joints, b *messages.Joints) bool {
+	legsA := [4][3]float32{a.LeftFront, a.RightFront, a.LeftBack, a.RightBack}
+	legsB := [4][3]float32{b.LeftFront, b.RightFront, b.LeftBack, b.RightBack}
+
+	for i := range legsA {
+		for j := range legsA[i] {
+			if math.Abs(legsA[i][j]-legsB[i][j]) > 1e-3 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func TestQuadControllerPublishesOnlyWhenPowered(t *testing.T) {
+	quadController := newController(testConfig())
+	currentTime := time.Now()
+
+	for i := 0; i < 3; i++ {
+		currentTime = currentTime.Add(tickPeriod)
+
+		if joints := quadController.Tick(currentTime); joints != nil {
+			t.Fatalf("powered off controller published %+v", joints)
+		}
+	}
+
+	if err := quadController.RequestState(StateResting); err != nil {
+		t.Fatal(err)
+	}
+
+	currentTime, joints := tickUntil(t, quadController, StateResting, currentTime)
+
+	if joints == nil || !jointsClose(joints, poseJoints(quadController, quadController.restPose())) {
+		t.Fatalf("resting controller published %+v, expected the rest pose", joints)
+	}
+
+	if err := quadController.RequestState(StatePoweredOff); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, joints := tickUntil(t, quadController, StatePoweredOff, currentTime); joints != nil {
+		t.Fatalf("controller published %+v after powering off", joints)
+	}
+}
+
+func TestQuadControllerTicksTheRequestedPose(t *testing.T) {
+	quadController := newController(testConfig())
+	currentTime := standUp(t, quadController)
+
+	nominal := poseJoints(quadController, quadController.nominalPose())
+
+	for i := 0; i < 3; i++ {
+		currentTime = currentTime.Add(tickPeriod)
+
+		if joints := quadController.Tick(currentTime); !jointsClose(joints, nominal) {
+			t.Fatalf("standing controller published %+v, expected the nominal stance %+v", joints, nominal)
+		}
+	}
+
+	pose := new(messages.Pose).Init()
+	pose.Z = -0.03
+	pose.Pitch = 0.1
+
+	if err := quadController.SetPose(pose); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := poseJoints(quadController, cstructs.Pose{
+		Position:    hmath.Vec3{0, 0, quadController.quadBase.GaitConfig().NominalHeight - 0.03},
+		Orientation: hmath.Vec3{0, 0.1, 0},
+	})
+
+	if joints := quadController.Tick(currentTime.Add(tickPeriod)); !jointsClose(joints, expected) {
+		t.Fatalf("controller published %+v for the new pose, expected %+v", joints, expected)
+	}
+
+	if applied := quadController.Pose(); math.Abs(applied.Z-pose.Z) > 1e-6 || applied.Pitch != pose.Pitch {
+		t.Fatalf("controller reports pose %+v, expected %+v", applied, pose)
+	}
+}
+
+func TestQuadControllerLoopStats(t *testing.T) {
+	period := 10 * time.Millisecond
+
+	ticks := []struct {
+		interval time.Duration
+		runTime  time.Duration
+	}{
+		{10 * time.Millisecond, 2 * time.Millisecond},
+		{12 * time.Millisecond, 3 * time.Millisecond},
+		{7 * time.Millisecond, 11 * time.Millisecond},
+		{10 * time.Millisecond, 15 * time.Millisecond},
+	}
+
+	quadController := newController(testConfig())
+
+	for _, tick := range ticks {
+		quadController.recordTick(period, tick.interval, tick.runTime)
+	}
+
+	expected := LoopStats{
+		Ticks:      4,
+		Overruns:   2,
+		MaxJitter:  3 * time.Millisecond,
+		MeanJitter: 1250 * time.Microsecond,
+		MaxRunTime: 15 * time.Millisecond,
+	}
+
+	if stats := quadController.Stats(); stats != expected {
+		t.Fatalf("loop stats %+v, expected %+v", stats, expected)
+	}
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidState = errors.New("command not allowed in the current state")
+
+// RobotState is the mode the controller is in, only the resting, standing, sitting and
+// powered off states can be requested, the others are entered by the controller
+type RobotState string
+
+const (
+	StatePoweredOff  RobotState = "powered-off" // no joints are published
+	StateResting     RobotState = "resting"     // lying on the floor
+	StateStandingUp  RobotState = "standing-up"
+	StateStanding    RobotState = "standing"
+	StateWalking     RobotState = "walking"
+	StateSittingDown RobotState = "sitting-down"
+	StateSitting     RobotState = "sitting"
+	StateLyingDown   RobotState = "lying-down"
+	StateEStopped    RobotState = "e-stopped"
+)
+
+// ParseTargetState returns the state with the given name if it can be requested
+func ParseTargetState(name string) (RobotState, error) {
+	switch state := RobotState(name); state {
+	case StatePoweredOff, StateResting, StateStanding, StateSitting:
+		return state, nil
+	default:
+		return "", fmt.Errorf("unknown target state %s, valid states are %s, %s, %s and %s", name,
+			StatePoweredOff, StateResting, StateStanding, StateSitting)
+	}
+}
+
+// Transitioning returns true for the states that play a joint trajectory
+func (robotState RobotState) Transitioning() bool {
+	return robotState == StateStandingUp || robotState == StateSittingDown || robotState == StateLyingDown
+}
+
+// next returns the state to move to from the current state on the way to the target,
+// the current state is returned when the target is reached or the controller has to
+// wait before moving on
+func (robotState RobotState) next(target RobotState) RobotState {
+	if robotState == target {
+		return robotState
+	}
+
+	switch robotState {
+	case StatePoweredOff:
+		return StateResting
+
+	case StateResting:
+		if target == StatePoweredOff {
+			return StatePoweredOff
+		}
+
+		return StateStandingUp
+
+	case StateStanding:
+		switch target {
+		case StateSitting:
+			return StateSittingDown
+		case StateResting, StatePoweredOff:
+			return StateLyingDown
+		}
+
+	case StateSitting:
+		switch target {
+		case StateStanding:
+			return StateStandingUp
+		case StateResting, StatePoweredOff:
+			return StateLyingDown
+		}
+	}
+
+	return robotState
+}
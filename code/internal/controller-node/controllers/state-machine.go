@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sitHeightScale = 0.8   // body height while sitting as a fraction of the nominal height
+	sitPitch       = -0.35 // body pitch while sitting in radians, lowers the rear
+)
+
+// RequestState sets the state the controller moves to, intermediate states and their
+// transitions are played in order. A request while walking stops the robot first
+func (quadController *QuadController) RequestState(target RobotState) error {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	if quadController.state == StateEStopped {
+		return mq.ErrEStopEngaged
+	}
+
+	if target != quadController.target {
+		logrus.Infof("moving from %s to %s", quadController.state, target)
+	}
+
+	quadController.target = target
+
+	if target != StateStanding {
+		quadController.requestedVelocities = cstructs.Velocities{}
+	}
+
+	return nil
+}
+
+// State returns the current state of the controller
+func (quadController *QuadController) State() *messages.RobotState {
+	quadController.mutex.Lock()
+	defer quadController.mutex.Unlock()
+
+	return quadController.stateMessage(time.Now())
+}
+
+// allowMotion returns an error when pose and velocity commands are not accepted in the
+// current state, it must be called with the mutex held
+func (quadController *QuadController) allowMotion(command string) error {
+	if quadController.state == StateEStopped {
+		return mq.ErrEStopEngaged
+	}
+
+	if quadController.target != StateStanding ||
+		(quadController.state != StateStanding && quadController.state != StateWalking) {
+		return fmt.Errorf("%w: %s can not be set while %s", ErrInvalidState, command, quadController.state)
+	}
+
+	return nil
+}
+
+func (quadController *QuadController) setState(state RobotState) {
+	if state != quadController.state {
+		logrus.Infof("state changed from %s to %s", quadController.state, state)
+	}
+
+	quadController.state = state
+}
+
+// stepState advances the state machine, it must be called with the mutex held. It returns
+// whether joints should be published and whether they come from the champ controllers,
+// otherwise the joint positions were set by a trajectory or are held
+func (quadController *QuadController) stepState(currentTime time.Time) (bool, bool) {
+	if quadController.trajectory != nil {
+		jointPositions, done := quadController.trajectory.Sample(quadController.jointPositions, currentTime)
+		quadController.jointPositions = jointPositions
+
+		if !done {
+			return true, false
+		}
+
+		quadController.trajectory = nil
+		quadController.setState(quadController.settled)
+	}
+
+	// Walking ends once the velocities, including a watchdog ramp, have reached zero
+	if quadController.state == StateWalking && quadController.requestedVelocities == (cstructs.Velocities{}) &&
+		quadController.appliedVelocities == (cstructs.Velocities{}) {
+		quadController.setState(StateStanding)
+	}
+
+	switch next := quadController.state.next(quadController.target); next {
+	case StateResting:
+		// Powering on, the servos move to the resting joints by themselves
+		quadController.jointPositions = quadController.kinematics.Inverse(quadController.jointPositions,
+			quadController.footPositionsFor(quadController.restPose()))
+		quadController.setState(StateResting)
+
+	case StatePoweredOff:
+		quadController.setState(StatePoweredOff)
+
+	case StateStandingUp:
+		quadController.requestedPose = quadController.nominalPose()
+		quadController.startTransition(StateStandingUp, StateStanding, quadController.nominalPose(), currentTime)
+
+	case StateSittingDown:
+		quadController.startTransition(StateSittingDown, StateSitting, quadController.sitPose(), currentTime)
+
+	case StateLyingDown:
+		quadController.startTransition(StateLyingDown, StateResting, quadController.restPose(), currentTime)
+	}
+
+	switch quadController.state {
+	case StatePoweredOff, StateEStopped:
+		return false, false
+
+	case StateStanding, StateWalking:
+		return true, true
+
+	default:
+		return true, false
+	}
+}
+
+func (quadController *QuadController) startTransition(state RobotState, settled RobotState, pose cstructs.Pose,
+	currentTime time.Time) {
+
+	quadController.trajectory = new(JointTrajectory).Init(quadController.kinematics, quadController.jointPositions,
+		quadController.footPositionsFor(pose), currentTime, quadController.config.TransitionTime)
+	quadController.settled = settled
+	quadController.setState(state)
+}
+
+func (quadController *QuadController) footPositionsFor(pose cstructs.Pose) [4]cstructs.Transformation {
+	return quadController.bodyController.PoseCommand([4]cstructs.Transformation{}, &pose)
+}
+
+func (quadController *QuadController) nominalPose() cstructs.Pose {
+	return cstructs.Pose{
+		Position: hmath.Vec3{0, 0, quadController.quadBase.GaitConfig().NominalHeight},
+	}
+}
+
+// restPose lowers the body as far as the body controller allows
+func (quadController *QuadController) restPose() cstructs.Pose {
+	return cstructs.Pose{}
+}
+
+func (quadController *QuadController) sitPose() cstructs.Pose {
+	return cstructs.Pose{
+		Position:    hmath.Vec3{0, 0, quadController.quadBase.GaitConfig().NominalHeight * sitHeightScale},
+		Orientation: hmath.Vec3{0, sitPitch, 0},
+	}
+}
+
+// stateMessage describes the current state, it must be called with the mutex held
+func (quadController *QuadController) stateMessage(currentTime time.Time) *messages.RobotState {
+	robotState := new(messages.RobotState).Init()
+	robotState.State = string(quadController.state)
+	robotState.Target = string(quadController.target)
+	robotState.Progress = 1.0
+
+	if quadController.trajectory != nil {
+		robotState.Progress = quadController.trajectory.Progress(currentTime)
+	}
+
+	return robotState
+}
+
+func (quadController *QuadController) publishState(robotState *messages.RobotState) {
+	publishError := quadController.nats.Publish(consts.MQStateChannel, robotState)
+	if publishError != nil && !errors.Is(publishError, mq.ErrDisconnected) {
+		logrus.Error(publishError)
+	}
+}
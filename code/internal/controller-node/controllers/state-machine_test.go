@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+)
+
+func TestRobotStateNext(t *testing.T) {
+	tests := []struct {
+		state    RobotState
+		target   RobotState
+		expected RobotState
+	}{
+		{StatePoweredOff, StatePoweredOff, StatePoweredOff},
+		{StatePoweredOff, StateResting, StateResting},
+		{StatePoweredOff, StateStanding, StateResting},
+		{StatePoweredOff, StateSitting, StateResting},
+		{StateResting, StateResting, StateResting},
+		{StateResting, StatePoweredOff, StatePoweredOff},
+		{StateResting, StateStanding, StateStandingUp},
+		{StateResting, StateSitting, StateStandingUp},
+		{StateStanding, StateStanding, StateStanding},
+		{StateStanding, StateSitting, StateSittingDown},
+		{StateStanding, StateResting, StateLyingDown},
+		{StateStanding, StatePoweredOff, StateLyingDown},
+		{StateSitting, StateSitting, StateSitting},
+		{StateSitting, StateStanding, StateStandingUp},
+		{StateSitting, StateResting, StateLyingDown},
+		{StateSitting, StatePoweredOff, StateLyingDown},
+
+		// Walking has to stop and the transitions have to finish before moving on
+		{StateWalking, StateSitting, StateWalking},
+		{StateStandingUp, StateSitting, StateStandingUp},
+		{StateSittingDown, StateStanding, StateSittingDown},
+		{StateLyingDown, StateStanding, StateLyingDown},
+
+		// Only Resume leaves the e-stopped state
+		{StateEStopped, StatePoweredOff, StateEStopped},
+		{StateEStopped, StateStanding, StateEStopped},
+	}
+
+	for _, test := range tests {
+		if next := test.state.next(test.target); next != test.expected {
+			t.Errorf("from %s to %s moved to %s, expected %s", test.state, test.target, next, test.expected)
+		}
+	}
+}
+
+func TestParseTargetState(t *testing.T) {
+	for _, name := range []string{"powered-off", "resting", "standing", "sitting"} {
+		if state, err := ParseTargetState(name); err != nil || state != RobotState(name) {
+			t.Errorf("%s parsed as %s: %v", name, state, err)
+		}
+	}
+
+	for _, name := range []string{"", "walking", "standing-up", "e-stopped", "Standing"} {
+		if state, err := ParseTargetState(name); err == nil {
+			t.Errorf("%s can be requested as %s", name, state)
+		}
+	}
+}
+
+// visitStates requests the target and returns the states the controller reports after each
+// tick until it settles in the target. A settled state on the way to the target is left on
+// the tick it is reached so it is not reported
+func visitStates(t *testing.T, quadController *QuadController, target RobotState, currentTime time.Time) ([]RobotState, time.Time) {
+	t.Helper()
+
+	if err := quadController.RequestState(target); err != nil {
+		t.Fatal(err)
+	}
+
+	var visited []RobotState
+
+	for i := 0; i < 1000; i++ {
+		currentTime = currentTime.Add(tickPeriod)
+		quadController.Tick(currentTime)
+
+		state := RobotState(quadController.State().State)
+
+		if len(visited) == 0 || visited[len(visited)-1] != state {
+			visited = append(visited, state)
+		}
+
+		if state == target {
+			return visited, currentTime
+		}
+	}
+
+	t.Fatalf("controller did not reach %s, it visited %v", target, visited)
+
+	return nil, currentTime
+}
+
+func TestQuadControllerStateTransitions(t *testing.T) {
+	tests := []struct {
+		target  RobotState
+		visited []RobotState
+	}{
+		{StateResting, []RobotState{StateResting}},
+		{StateSitting, []RobotState{StateStandingUp, StateSittingDown, StateSitting}},
+		{StateStanding, []RobotState{StateStandingUp, StateStanding}},
+		{StateResting, []RobotState{StateLyingDown, StateResting}},
+		{StateStanding, []RobotState{StateStandingUp, StateStanding}},
+		{StatePoweredOff, []RobotState{StateLyingDown, StatePoweredOff}},
+		{StateSitting, []RobotState{StateResting, StateStandingUp, StateSittingDown, StateSitting}},
+		{StatePoweredOff, []RobotState{StateLyingDown, StatePoweredOff}},
+	}
+
+	quadController := newController(testConfig())
+	currentTime := time.Now()
+
+	for _, test := range tests {
+		from := quadController.State().State
+
+		var visited []RobotState
+		visited, currentTime = visitStates(t, quadController, test.target, currentTime)
+
+		if !reflect.DeepEqual(visited, test.visited) {
+			t.Errorf("from %s to %s visited %v, expected %v", from, test.target, visited, test.visited)
+		}
+
+		if robotState := quadController.State(); robotState.Target != string(test.target) || robotState.Progress != 1 {
+			t.Errorf("settled in %s with %+v", test.target, robotState)
+		}
+	}
+}
+
+func TestQuadControllerAllowsMotionOnlyWhileStanding(t *testing.T) {
+	quadController := newController(testConfig())
+	currentTime := time.Now()
+
+	walking := cstructs.Velocities{Linear: hmath.Vec3{0.1, 0, 0}}
+
+	for _, target := range []RobotState{StatePoweredOff, StateResting, StateSitting} {
+		_, currentTime = visitStates(t, quadController, target, currentTime)
+
+		if err := quadController.SetPose(new(messages.Pose).Init()); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("pose while %s returned %v, expected %v", target, err, ErrInvalidState)
+		}
+
+		if _, err := quadController.SetVelocities(walking); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("velocities while %s returned %v, expected %v", target, err, ErrInvalidState)
+		}
+
+		// Stopping is always accepted
+		if _, err := quadController.SetVelocities(cstructs.Velocities{}); err != nil {
+			t.Errorf("zero velocities while %s returned %s", target, err)
+		}
+	}
+
+	// Commands are refused during a transition
+	if err := quadController.RequestState(StateStanding); err != nil {
+		t.Fatal(err)
+	}
+
+	currentTime = currentTime.Add(tickPeriod)
+	quadController.Tick(currentTime)
+
+	if state := RobotState(quadController.State().State); state != StateStandingUp {
+		t.Fatalf("controller is %s, expected %s", state, StateStandingUp)
+	}
+
+	if err := quadController.SetPose(new(messages.Pose).Init()); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("pose while standing up returned %v, expected %v", err, ErrInvalidState)
+	}
+
+	currentTime, _ = tickUntil(t, quadController, StateStanding, currentTime)
+
+	if err := quadController.SetPose(new(messages.Pose).Init()); err != nil {
+		t.Fatalf("pose while standing refused: %s", err)
+	}
+
+	if _, err := quadController.SetVelocities(walking); err != nil {
+		t.Fatalf("velocities while standing refused: %s", err)
+	}
+
+	currentTime = currentTime.Add(tickPeriod)
+	quadController.Tick(currentTime)
+
+	// Another target stops the robot before moving on and refuses new velocities
+	if err := quadController.RequestState(StateSitting); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := quadController.SetVelocities(walking); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("velocities after requesting to sit returned %v, expected %v", err, ErrInvalidState)
+	}
+
+	visited, _ := visitStates(t, quadController, StateSitting, currentTime)
+
+	if expected := []RobotState{StateWalking, StateSittingDown, StateSitting}; !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("walking controller visited %v on the way to sit, expected %v", visited, expected)
+	}
+}
+
+func TestQuadControllerEStopAndResume(t *testing.T) {
+	quadController := newController(testConfig())
+	currentTime := standUp(t, quadController)
+
+	quadController.EStop("test")
+
+	if state := RobotState(quadController.State().State); state != StateEStopped {
+		t.Fatalf("controller is %s, expected %s", state, StateEStopped)
+	}
+
+	for i := 0; i < 3; i++ {
+		currentTime = currentTime.Add(tickPeriod)
+
+		if joints := quadController.Tick(currentTime); joints != nil {
+			t.Fatalf("e-stopped controller published %+v", joints)
+		}
+	}
+
+	if err := quadController.RequestState(StateStanding); !errors.Is(err, mq.ErrEStopEngaged) {
+		t.Errorf("state request while e-stopped returned %v, expected %v", err, mq.ErrEStopEngaged)
+	}
+
+	if err := quadController.SetPose(new(messages.Pose).Init()); !errors.Is(err, mq.ErrEStopEngaged) {
+		t.Errorf("pose while e-stopped returned %v, expected %v", err, mq.ErrEStopEngaged)
+	}
+
+	if _, err := quadController.SetVelocities(cstructs.Velocities{}); !errors.Is(err, mq.ErrEStopEngaged) {
+		t.Errorf("velocities while e-stopped returned %v, expected %v", err, mq.ErrEStopEngaged)
+	}
+
+	if err := quadController.SetGait("trot"); !errors.Is(err, mq.ErrEStopEngaged) {
+		t.Errorf("gait while e-stopped returned %v, expected %v", err, mq.ErrEStopEngaged)
+	}
+
+	// The servos were turned off, after resuming the robot has to be brought up again
+	quadController.Resume()
+
+	if robotState := quadController.State(); robotState.State != string(StatePoweredOff) ||
+		robotState.Target != string(StatePoweredOff) {
+		t.Fatalf("resumed controller is %+v, expected %s", robotState, StatePoweredOff)
+	}
+
+	visited, _ := visitStates(t, quadController, StateStanding, currentTime)
+
+	if expected := []RobotState{StateResting, StateStandingUp, StateStanding}; !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("resumed controller visited %v, expected %v", visited, expected)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/internal/controller-node/controllers"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cbase"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+// handlerFixture runs the pose and velocity handlers behind an mq.Service on an in-process
+// nats server
+type handlerFixture struct {
+	client     *mq.Nats
+	controller *controllers.QuadController
+	joints     chan *nats.Msg
+}
+
+func newQuadBase() *cbase.QuadBase {
+	quadBase := new(cbase.QuadBase).Init(*new(cstructs.GaitConfig).Defaults())
+	quadBase.SetGeometry(*new(cstructs.QuadGeometry).Defaults())
+
+	return quadBase
+}
+
+func connect(t *testing.T, natsConfig structs.NatsConfig, name string) *mq.Nats {
+	natsConfig.Name = name
+
+	connection := new(mq.Nats).Init(natsConfig)
+
+	if err := connection.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(connection.Conn.Close)
+
+	return connection
+}
+
+func startHandlerFixture(t *testing.T) *handlerFixture {
+	server := new(mq.Server).Init(structs.NatsConfig{Host: "127.0.0.1:-1"})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(server.Shutdown)
+
+	natsConfig := structs.NatsConfig{Host: server.Addr()}
+
+	config := *new(structs.ControllerConfig).Defaults()
+	config.LoopRate = 200
+	config.CommandTimeout = 0
+	config.TransitionTime = 50 * time.Millisecond
+
+	node := connect(t, natsConfig, consts.NodeNameController)
+	controller := new(controllers.QuadController).Init(node, newQuadBase(), config)
+
+	service := new(mq.Service).Init(node, 1)
+	service.Handle(messages.PoseMessage, new(PoseHandler).Init(controller).Handle)
+	service.Handle(messages.VelocitiesMessage, new(VelocityHandler).Init(node, controller).Handle)
+
+	for _, channel := range []string{consts.MQPoseSetChannel, consts.MQCmdVelChannel} {
+		if err := service.Subscribe(channel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{}, 2)
+
+	go func() {
+		service.Run(stop)
+		done <- struct{}{}
+	}()
+
+	go func() {
+		controller.Run(stop)
+		done <- struct{}{}
+	}()
+
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+		<-done
+	})
+
+	fixture := &handlerFixture{
+		client:     connect(t, natsConfig, "test"),
+		controller: controller,
+		joints:     make(chan *nats.Msg, 1000),
+	}
+
+	if _, err := fixture.client.Conn.ChanSubscribe(consts.MQJointSetChannel, fixture.joints); err != nil {
+		t.Fatal(err)
+	}
+
+	return fixture
+}
+
+func (fixture *handlerFixture) stand(t *testing.T) {
+	if err := fixture.controller.RequestState(controllers.StateStanding); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for fixture.controller.State().State != string(controllers.StateStanding) {
+		if time.Now().After(deadline) {
+			t.Fatalf("robot did not stand up, state %s", fixture.controller.State().State)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
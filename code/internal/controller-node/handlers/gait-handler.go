@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/r4stl1n/micro-hal/code/internal/controller-node/controllers"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+)
+
+type GaitHandler struct {
+	quadController *controllers.QuadController
+}
+
+func (gaitHandler *GaitHandler) Init(quadController *controllers.QuadController) *GaitHandler {
+	*gaitHandler = GaitHandler{
+		quadController: quadController,
+	}
+
+	return gaitHandler
+}
+
+// Handle requests the gait switch, an unknown gait is replied to with a failure result
+func (gaitHandler *GaitHandler) Handle(request *mq.Request, _ *mq.Responder) (interface{}, error) {
+
+	message := new(messages.Gait)
+
+	unpackError := request.Unpack(message)
+	if unpackError != nil {
+		return nil, unpackError
+	}
+
+	return nil, gaitHandler.quadController.SetGait(message.Name)
+}
@@ -1,22 +1,39 @@
 package handlers
 
 import (
+	"github.com/r4stl1n/micro-hal/code/internal/controller-node/controllers"
 	"github.com/r4stl1n/micro-hal/code/pkg/messages"
 	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/sirupsen/logrus"
 )
 
 type PoseHandler struct {
-	nats *mq.Nats
+	quadController *controllers.QuadController
 }
 
-func (poseHandler *PoseHandler) Init(nats *mq.Nats) *PoseHandler {
+func (poseHandler *PoseHandler) Init(quadController *controllers.QuadController) *PoseHandler {
 	*poseHandler = PoseHandler{
-		nats: nats,
+		quadController: quadController,
 	}
 
 	return poseHandler
 }
 
-func (poseHandler *PoseHandler) Handle(message *messages.Pose) {
+func (poseHandler *PoseHandler) Handle(request *mq.Request, _ *mq.Responder) (interface{}, error) {
 
+	message := new(messages.Pose)
+
+	unpackError := request.Unpack(message)
+	if unpackError != nil {
+		return nil, unpackError
+	}
+
+	logrus.Debugf("Setting requested pose: %+v", message)
+
+	return nil, poseHandler.quadController.SetPose(message)
+}
+
+// HandleGet replies with the body pose currently applied by the controller
+func (poseHandler *PoseHandler) HandleGet(_ *mq.Request, _ *mq.Responder) (interface{}, error) {
+	return poseHandler.quadController.Pose(), nil
 }
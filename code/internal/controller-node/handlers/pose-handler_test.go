@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	math "github.com/chewxy/math32"
+	"github.com/r4stl1n/micro-hal/code/internal/controller-node/controllers"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+)
+
+func (fixture *handlerFixture) setPose(pose *messages.Pose) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return fixture.client.Request(ctx, consts.MQPoseSetChannel, pose, new(messages.Result))
+}
+
+// expectedJoints runs the body controller and inverse kinematics for the pose on a separate
+// quad base the same way the controller does while standing still
+func expectedJoints(pose *messages.Pose) *messages.Joints {
+	quadBase := newQuadBase()
+
+	bodyPose := cstructs.Pose{
+		Position:    hmath.Vec3{pose.X, pose.Y, quadBase.GaitConfig().NominalHeight + pose.Z},
+		Orientation: hmath.Vec3{pose.Roll, pose.Pitch, pose.Yaw},
+	}
+
+	footPositions := new(champ.BodyController).Init(quadBase).PoseCommand([4]cstructs.Transformation{}, &bodyPose)
+
+	return controllers.JointsFromPositions(new(champ.Kinematics).Init(quadBase).Inverse([12]float32{}, footPositions))
+}
+
+func jointsClose(a *messages.Joints, b *messages.Joints, tolerance float32) bool {
+	for _, legs := range [][2]hmath.Vec3{
+		{a.LeftFront, b.LeftFront}, {a.RightFront, b.RightFront}, {a.LeftBack, b.LeftBack}, {a.RightBack, b.RightBack},
+	} {
+		for i := 0; i < 3; i++ {
+			if math.Abs(legs[0][i]-legs[1][i]) > tolerance {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// waitForJoints reads the published joints until they match the expected joints
+func (fixture *handlerFixture) waitForJoints(t *testing.T, expected *messages.Joints) {
+	timeout := time.After(2 * time.Second)
+
+	var last *messages.Joints
+
+	for {
+		select {
+		case msg := <-fixture.joints:
+			message := new(messages.Message)
+
+			if err := message.Unpack(msg.Data); err != nil {
+				t.Fatal(err)
+			}
+
+			if message.Type != messages.JointsMessage {
+				t.Fatalf("expected a joints message on %s, got type %d", consts.MQJointSetChannel, message.Type)
+			}
+
+			last = new(messages.Joints)
+
+			if err := last.Unpack(message.Data); err != nil {
+				t.Fatal(err)
+			}
+
+			if jointsClose(last, expected, 1e-4) {
+				return
+			}
+
+		case <-timeout:
+			t.Fatalf("published joints %+v never matched the expected joints %+v", last, expected)
+		}
+	}
+}
+
+func TestPoseHandlerPublishesJoints(t *testing.T) {
+	fixture := startHandlerFixture(t)
+	fixture.stand(t)
+
+	for _, pose := range []*messages.Pose{
+		{Z: -0.02, Roll: 0.1},
+		{X: 0.01, Pitch: -0.1, Yaw: 0.05},
+		{},
+	} {
+		if err := fixture.setPose(pose); err != nil {
+			t.Fatalf("pose %+v was refused: %s", pose, err)
+		}
+
+		fixture.waitForJoints(t, expectedJoints(pose))
+	}
+}
+
+func TestPoseHandlerMovesTheBody(t *testing.T) {
+	fixture := startHandlerFixture(t)
+	fixture.stand(t)
+
+	if jointsClose(expectedJoints(&messages.Pose{}), expectedJoints(&messages.Pose{Z: -0.02, Roll: 0.1}), 1e-3) {
+		t.Fatal("the test pose does not change the joints")
+	}
+
+	pose := &messages.Pose{Z: -0.02, Roll: 0.1}
+
+	if err := fixture.setPose(pose); err != nil {
+		t.Fatal(err)
+	}
+
+	// The z value goes through the nominal height so it is only compared within a tolerance
+	applied := fixture.controller.Pose()
+	if math.Abs(applied.Z-pose.Z) > 1e-6 || applied.Roll != pose.Roll || applied.X != 0 || applied.Pitch != 0 {
+		t.Fatalf("controller applied pose %+v, expected %+v", applied, pose)
+	}
+}
+
+func TestPoseHandlerRefusesPoseWhilePoweredOff(t *testing.T) {
+	fixture := startHandlerFixture(t)
+
+	err := fixture.setPose(&messages.Pose{Z: -0.02})
+
+	var remoteError *mq.RemoteError
+	if !errors.As(err, &remoteError) {
+		t.Fatalf("expected a remote error, got %v", err)
+	}
+
+	select {
+	case <-fixture.joints:
+		t.Fatal("joints were published while powered off")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPoseHandlerRejectsInvalidPayload(t *testing.T) {
+	fixture := startHandlerFixture(t)
+	fixture.stand(t)
+
+	// 0xc1 is never used by msgpack so the data can not be unpacked into a pose
+	message := new(messages.Message).Request("")
+	message.Type = messages.PoseMessage
+	message.Data = []byte{0xc1}
+
+	response, err := fixture.client.SendAwaitResponse(consts.MQPoseSetChannel, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := new(messages.Result)
+	if err := result.Unpack(response.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Type != messages.FailureResult {
+		t.Fatalf("expected a failure result, got %+v", result)
+	}
+}
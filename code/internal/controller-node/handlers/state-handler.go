@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"github.com/r4stl1n/micro-hal/code/internal/controller-node/controllers"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+)
+
+type StateHandler struct {
+	quadController *controllers.QuadController
+}
+
+func (stateHandler *StateHandler) Init(quadController *controllers.QuadController) *StateHandler {
+	*stateHandler = StateHandler{
+		quadController: quadController,
+	}
+
+	return stateHandler
+}
+
+// Handle requests the target state and replies with the state of the controller, an
+// unknown target is replied to with a failure result
+func (stateHandler *StateHandler) Handle(request *mq.Request, _ *mq.Responder) (interface{}, error) {
+
+	message := new(messages.StateRequest)
+
+	unpackError := request.Unpack(message)
+	if unpackError != nil {
+		return nil, unpackError
+	}
+
+	target, parseError := controllers.ParseTargetState(message.Target)
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	requestError := stateHandler.quadController.RequestState(target)
+	if requestError != nil {
+		return nil, requestError
+	}
+
+	return stateHandler.quadController.State(), nil
+}
+
+// HandleGet replies with the state of the controller
+func (stateHandler *StateHandler) HandleGet(_ *mq.Request, _ *mq.Responder) (interface{}, error) {
+	return stateHandler.quadController.State(), nil
+}
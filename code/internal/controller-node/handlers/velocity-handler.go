@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"github.com/r4stl1n/micro-hal/code/internal/controller-node/controllers"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/sirupsen/logrus"
+)
+
+type VelocityHandler struct {
+	nats           *mq.Nats
+	quadController *controllers.QuadController
+}
+
+func (velocityHandler *VelocityHandler) Init(nats *mq.Nats, quadController *controllers.QuadController) *VelocityHandler {
+	*velocityHandler = VelocityHandler{
+		nats:           nats,
+		quadController: quadController,
+	}
+
+	return velocityHandler
+}
+
+// Handle applies the requested velocities and echoes back the clamped values on the
+// applied channel and as the reply to the request
+func (velocityHandler *VelocityHandler) Handle(request *mq.Request, _ *mq.Responder) (interface{}, error) {
+
+	message := new(messages.Velocities)
+
+	unpackError := request.Unpack(message)
+	if unpackError != nil {
+		return nil, unpackError
+	}
+
+	velocities := cstructs.Velocities{}
+	velocities.Linear.SetX(message.LinearX)
+	velocities.Linear.SetY(message.LinearY)
+	velocities.Angular.SetZ(message.AngularZ)
+
+	velocities, setError := velocityHandler.quadController.SetVelocities(velocities)
+	if setError != nil {
+		return nil, setError
+	}
+
+	applied := new(messages.Velocities).Init()
+	applied.LinearX = velocities.Linear.X()
+	applied.LinearY = velocities.Linear.Y()
+	applied.AngularZ = velocities.Angular.Z()
+
+	logrus.Debugf("Requested velocities: %+v, applied velocities: %+v", message, applied)
+
+	publishError := velocityHandler.nats.Publish(consts.MQCmdVelAppliedChannel, applied)
+	if publishError != nil {
+		return nil, publishError
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/internal/controller-node/controllers"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+)
+
+func (fixture *handlerFixture) setVelocities(velocities *messages.Velocities) (*messages.Velocities, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	applied := new(messages.Velocities)
+
+	return applied, fixture.client.Request(ctx, consts.MQCmdVelChannel, velocities, applied)
+}
+
+func TestVelocityHandlerClampsAndEchoes(t *testing.T) {
+	fixture := startHandlerFixture(t)
+	fixture.stand(t)
+
+	echoes := make(chan *nats.Msg, 16)
+
+	if _, err := fixture.client.Conn.ChanSubscribe(consts.MQCmdVelAppliedChannel, echoes); err != nil {
+		t.Fatal(err)
+	}
+
+	// The default gait config allows 0.5 m/s forward, 0.25 m/s sideways and 1 rad/s of turning
+	tests := []struct {
+		name      string
+		requested messages.Velocities
+		applied   messages.Velocities
+	}{
+		{"within limits", messages.Velocities{LinearX: 0.2, LinearY: -0.1, AngularZ: 0.5},
+			messages.Velocities{LinearX: 0.2, LinearY: -0.1, AngularZ: 0.5}},
+		{"above limits", messages.Velocities{LinearX: 2, LinearY: 1, AngularZ: 3},
+			messages.Velocities{LinearX: 0.5, LinearY: 0.25, AngularZ: 1}},
+		{"below limits", messages.Velocities{LinearX: -2, LinearY: -1, AngularZ: -3},
+			messages.Velocities{LinearX: -0.5, LinearY: -0.25, AngularZ: -1}},
+		{"stop", messages.Velocities{}, messages.Velocities{}},
+	}
+
+	for _, test := range tests {
+		applied, err := fixture.setVelocities(&test.requested)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if *applied != test.applied {
+			t.Errorf("%s: replied with %+v, expected %+v", test.name, *applied, test.applied)
+		}
+
+		select {
+		case msg := <-echoes:
+			message := new(messages.Message)
+			echoed := new(messages.Velocities)
+
+			if err := message.Unpack(msg.Data); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := echoed.Unpack(message.Data); err != nil {
+				t.Fatal(err)
+			}
+
+			if *echoed != test.applied {
+				t.Errorf("%s: echoed %+v, expected %+v", test.name, *echoed, test.applied)
+			}
+
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: applied velocities were not echoed", test.name)
+		}
+	}
+}
+
+func TestVelocityHandlerStartsAndStopsWalking(t *testing.T) {
+	fixture := startHandlerFixture(t)
+	fixture.stand(t)
+
+	if _, err := fixture.setVelocities(&messages.Velocities{LinearX: 0.2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := fixture.controller.State().State; state != string(controllers.StateWalking) {
+		t.Fatalf("controller is %s after a velocity command, expected %s", state, controllers.StateWalking)
+	}
+
+	if _, err := fixture.setVelocities(&messages.Velocities{}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for fixture.controller.State().State != string(controllers.StateStanding) {
+		if time.Now().After(deadline) {
+			t.Fatalf("controller did not stop walking, state %s", fixture.controller.State().State)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestVelocityHandlerRefusesWalkingWhilePoweredOff(t *testing.T) {
+	fixture := startHandlerFixture(t)
+
+	_, err := fixture.setVelocities(&messages.Velocities{LinearX: 0.2})
+
+	var remoteError *mq.RemoteError
+	if !errors.As(err, &remoteError) {
+		t.Fatalf("expected a remote error, got %v", err)
+	}
+
+	// Stopping is always accepted so a stale client can not be refused a stop
+	if _, err := fixture.setVelocities(&messages.Velocities{}); err != nil {
+		t.Fatalf("zero velocities refused while powered off: %s", err)
+	}
+}
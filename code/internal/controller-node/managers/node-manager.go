@@ -1,78 +1,202 @@
 package managers
 
 import (
+	"fmt"
+	"github.com/r4stl1n/micro-hal/code/internal/controller-node/controllers"
 	"github.com/r4stl1n/micro-hal/code/internal/controller-node/handlers"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cbase"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/urdf"
 	"github.com/r4stl1n/micro-hal/code/pkg/consts"
 	"github.com/r4stl1n/micro-hal/code/pkg/messages"
 	"github.com/r4stl1n/micro-hal/code/pkg/mq"
 	"github.com/r4stl1n/micro-hal/code/pkg/structs"
 	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
 )
 
 type NodeManager struct {
-	nats *mq.Nats
+	nats   *mq.Nats
+	config structs.ControllerConfig
 
-	poseHandler *handlers.PoseHandler
+	quadBase       *cbase.QuadBase
+	quadController *controllers.QuadController
+
+	poseHandler     *handlers.PoseHandler
+	velocityHandler *handlers.VelocityHandler
+	gaitHandler     *handlers.GaitHandler
+	stateHandler    *handlers.StateHandler
+
+	eStopLatch *mq.EStopLatch
+
+	stopChannel chan struct{}
+	stopOnce    sync.Once
 }
 
-func (nodeManager *NodeManager) Init() *NodeManager {
+func (nodeManager *NodeManager) Init(natsConfig structs.NatsConfig, config structs.ControllerConfig) (*NodeManager, error) {
+	if natsConfig.Name == "" {
+		natsConfig.Name = consts.NodeNameController
+	}
+
 	*nodeManager = NodeManager{
-		nats: new(mq.Nats).Init(*new(structs.NatsConfig).Defaults()),
+		nats:        new(mq.Nats).Init(natsConfig),
+		config:      config,
+		stopChannel: make(chan struct{}),
+	}
+
+	geometry, err := nodeManager.loadGeometry(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nodeManager.quadBase = new(cbase.QuadBase).Init(*new(cstructs.GaitConfig).Defaults())
+	nodeManager.quadBase.SetGeometry(geometry)
+
+	nodeManager.quadController = new(controllers.QuadController).Init(nodeManager.nats, nodeManager.quadBase, config)
+
+	nodeManager.poseHandler = new(handlers.PoseHandler).Init(nodeManager.quadController)
+	nodeManager.velocityHandler = new(handlers.VelocityHandler).Init(nodeManager.nats, nodeManager.quadController)
+	nodeManager.gaitHandler = new(handlers.GaitHandler).Init(nodeManager.quadController)
+	nodeManager.stateHandler = new(handlers.StateHandler).Init(nodeManager.quadController)
+
+	nodeManager.eStopLatch = new(mq.EStopLatch).Init(nodeManager.nats, config.EStopResetToken,
+		func(status *messages.EStopStatus) { nodeManager.quadController.EStop(status.Reason) },
+		nodeManager.quadController.Resume)
+
+	return nodeManager, nil
+}
+
+func (nodeManager *NodeManager) loadGeometry(config structs.ControllerConfig) (cstructs.QuadGeometry, error) {
+	jointNames, err := nodeManager.loadJointNames(config)
+
+	if err != nil {
+		return cstructs.QuadGeometry{}, err
+	}
+
+	if config.URDFPath == "" {
+		return *new(cstructs.QuadGeometry).Defaults(), nil
+	}
+
+	logrus.Infof("Loading robot geometry from %s", config.URDFPath)
+
+	return urdf.Load(config.URDFPath, jointNames)
+}
+
+// loadJointNames reads the urdf joint names from the config, they are either a json
+// document or the path of a json file
+func (nodeManager *NodeManager) loadJointNames(config structs.ControllerConfig) (urdf.JointNames, error) {
+	jointNames := *new(urdf.JointNames).Defaults()
+	source := strings.TrimSpace(config.URDFJointNames)
+
+	var err error
+
+	switch {
+	case source == "":
+		return jointNames, nil
+
+	case strings.HasPrefix(source, "{"):
+		err = jointNames.Unmarshal([]byte(source))
+
+	default:
+		err = jointNames.Load(source)
 	}
 
-	nodeManager.poseHandler = new(handlers.PoseHandler).Init(nodeManager.nats)
+	if err != nil {
+		return jointNames, fmt.Errorf("failed to load the urdf joint names: %w", err)
+	}
 
-	return nodeManager
+	return jointNames, nil
 }
 
 func (nodeManager *NodeManager) connectToNats() error {
 	return nodeManager.nats.Connect()
 }
 
+// Stop stops the control loop and makes Process return
+func (nodeManager *NodeManager) Stop() {
+	nodeManager.stopOnce.Do(func() {
+		close(nodeManager.stopChannel)
+	})
+}
+
+func (nodeManager *NodeManager) handleConnectionEvent(event mq.ConnectionEvent) {
+	switch event.State {
+	case mq.StateDisconnected:
+		// Commands can not reach the controller anymore so it must not keep walking
+		nodeManager.quadController.Halt("lost the connection to nats")
+
+	case mq.StateReconnected:
+		logrus.Info("connection to nats restored")
+
+	case mq.StateClosed:
+		logrus.Error("connection to nats closed, stopping")
+		nodeManager.Stop()
+	}
+}
+
+// handleQuery routes the queries to the get handler of the channel they were sent on
+func (nodeManager *NodeManager) handleQuery(request *mq.Request, responder *mq.Responder) (interface{}, error) {
+	if request.Channel == consts.MQStateGetChannel {
+		return nodeManager.stateHandler.HandleGet(request, responder)
+	}
+
+	return nodeManager.poseHandler.HandleGet(request, responder)
+}
+
 func (nodeManager *NodeManager) Process() error {
 
+	nodeManager.nats.OnConnectionEvent(nodeManager.handleConnectionEvent)
+
 	connectToNatsError := nodeManager.connectToNats()
 	if connectToNatsError != nil {
 		return connectToNatsError
 	}
 
-	receiveChannel := make(chan *[]byte, 100)
-	_, bindError := nodeManager.nats.EncodedConn.BindRecvChan(consts.MQPoseSetChannel, receiveChannel)
-	if bindError != nil {
-		return bindError
+	service := new(mq.Service).Init(nodeManager.nats, 1)
+	service.Handle(messages.PoseMessage, nodeManager.poseHandler.Handle)
+	service.Handle(messages.VelocitiesMessage, nodeManager.velocityHandler.Handle)
+	service.Handle(messages.GaitMessage, nodeManager.gaitHandler.Handle)
+	service.Handle(messages.StateRequestMessage, nodeManager.stateHandler.Handle)
+	service.Handle(messages.QueryMessage, nodeManager.handleQuery)
+	service.Filter(new(mq.CommandFilter).Init(nodeManager.config.CommandMaxAge), messages.PoseMessage, messages.VelocitiesMessage)
+
+	channels := []string{consts.MQPoseSetChannel, consts.MQPoseGetChannel, consts.MQCmdVelChannel, consts.MQGaitSetChannel,
+		consts.MQStateSetChannel, consts.MQStateGetChannel}
+
+	for _, channel := range channels {
+		subscribeError := service.Subscribe(channel)
+		if subscribeError != nil {
+			return subscribeError
+		}
 	}
 
-	logrus.Info("service started waiting for messages")
-
-	for {
-		select {
-		case receiveData := <-receiveChannel:
-			requestMessage := new(messages.Message)
-			requestError := requestMessage.Unpack(*receiveData)
-			if requestError != nil {
-				logrus.Error(requestError)
-				continue
-			}
+	// Emergency stops get their own service so they are not queued behind other commands
+	eStopService := new(mq.Service).Init(nodeManager.nats, 1)
 
-			logrus.Debugf("Received Message: %+v", requestMessage)
+	registerError := nodeManager.eStopLatch.Register(eStopService)
+	if registerError != nil {
+		return registerError
+	}
 
-			switch requestMessage.Type {
+	eStopDone := make(chan struct{})
 
-			case messages.PoseMessage:
-				sMessage := new(messages.Pose)
+	go func() {
+		eStopService.Run(nodeManager.stopChannel)
+		close(eStopDone)
+	}()
 
-				unpackError := sMessage.Unpack(requestMessage.Data)
-				if unpackError != nil {
-					logrus.Error(unpackError)
-					continue
-				}
+	go nodeManager.quadController.Run(nodeManager.stopChannel)
 
-				nodeManager.poseHandler.Handle(sMessage)
+	logrus.Info("service started waiting for messages")
 
-			default:
-				logrus.Errorf("unknown message received %v+", requestMessage)
-			}
+	service.Run(nodeManager.stopChannel)
+	<-eStopDone
 
-		}
+	if nodeManager.nats.Closed() {
+		return mq.ErrConnectionClosed
 	}
+
+	return nil
 }
@@ -0,0 +1,48 @@
+package managers
+
+import (
+	"testing"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+const (
+	testURDF       = "../../../pkg/champ/urdf/testdata/quad.urdf"
+	testJointNames = "../../../pkg/champ/urdf/testdata/joint-names.json"
+)
+
+func TestNodeManagerLoadsTheGeometry(t *testing.T) {
+	tests := []struct {
+		name       string
+		urdfPath   string
+		jointNames string
+		fails      bool
+	}{
+		{"built in geometry", "", "", false},
+		{"joint names file", testURDF, testJointNames, false},
+		{"joint names document", testURDF, `{"legs": [{"hip": "lf_hip", "upperLeg": "lf_upper", "lowerLeg": "lf_lower", "foot": "lf_foot"},
+			{"hip": "rf_hip", "upperLeg": "rf_upper", "lowerLeg": "rf_lower", "foot": "rf_foot"},
+			{"hip": "lb_hip", "upperLeg": "lb_upper", "lowerLeg": "lb_lower", "foot": "lb_foot"},
+			{"hip": "rb_hip", "upperLeg": "rb_upper", "lowerLeg": "rb_lower", "foot": "rb_foot"}]}`, false},
+		{"built in joint names missing from the urdf", testURDF, "", true},
+		{"invalid joint names document", "", `{"legs": [`, true},
+		{"missing joint names file", "", "./missing-joint-names.json", true},
+		{"missing urdf", "./missing.urdf", testJointNames, true},
+	}
+
+	for _, test := range tests {
+		config := *new(structs.ControllerConfig).Defaults()
+		config.URDFPath = test.urdfPath
+		config.URDFJointNames = test.jointNames
+
+		_, err := new(NodeManager).Init(structs.NatsConfig{}, config)
+
+		if test.fails && err == nil {
+			t.Errorf("%s: node manager started", test.name)
+		}
+
+		if !test.fails && err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		}
+	}
+}
@@ -57,6 +57,7 @@ func (c *CLI) Init() *CLI {
 
 	c.rootCommand.AddCommand(new(cmds.Servo).Init().Command())
 	c.rootCommand.AddCommand(new(cmds.Utils).Init().Command())
+	c.rootCommand.AddCommand(new(cmds.Robot).Init().Command())
 	return c
 }
 
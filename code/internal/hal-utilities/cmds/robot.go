@@ -0,0 +1,33 @@
+package cmds
+
+import (
+	"github.com/r4stl1n/micro-hal/code/internal/hal-utilities/cmds/robot"
+	"github.com/spf13/cobra"
+)
+
+type Robot struct {
+}
+
+func (cmd *Robot) Init() *Robot {
+	*cmd = Robot{}
+
+	return cmd
+}
+
+func (cmd *Robot) Command() *cobra.Command {
+	command := &cobra.Command{
+		Use:                   "robot",
+		Aliases:               []string{"r"},
+		DisableFlagsInUseLine: true,
+		Short:                 "commands for the running robot nodes",
+	}
+
+	command.AddCommand(new(robot.Joints).Init().Command())
+	command.AddCommand(new(robot.Pose).Init().Command())
+	command.AddCommand(new(robot.State).Init().Command())
+	command.AddCommand(new(robot.EStop).Init().Command())
+	command.AddCommand(new(robot.EStopStatus).Init().Command())
+	command.AddCommand(new(robot.EStopReset).Init().Command())
+
+	return command
+}
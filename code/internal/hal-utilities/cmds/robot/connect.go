@@ -0,0 +1,41 @@
+package robot
+
+import (
+	"context"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+const (
+	requestTimeout = 5 * time.Second
+	gatherIdle     = 500 * time.Millisecond // replies from every node are collected until none arrive for this long
+)
+
+// connect connects to the nats server the robot nodes are using, the caller closes the
+// connection when done with it
+func connect() (*mq.Nats, error) {
+	natsConfig, err := new(structs.NatsConfig).Defaults()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if natsConfig.Name == "" {
+		natsConfig.Name = "hal-utilities"
+	}
+
+	nats := new(mq.Nats).Init(*natsConfig)
+
+	if err := nats.Connect(); err != nil {
+		nats.Close()
+		return nil, err
+	}
+
+	return nats, nil
+}
+
+func requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), requestTimeout)
+}
@@ -0,0 +1,41 @@
+package robot
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+type EStopReset struct {
+}
+
+func (cmd *EStopReset) Init() *EStopReset {
+	*cmd = EStopReset{}
+
+	return cmd
+}
+
+func (cmd *EStopReset) Command() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "estopReset",
+		Aliases:               []string{"esr"},
+		Args:                  cobra.MaximumNArgs(1),
+		ArgAliases:            []string{"token"},
+		DisableFlagsInUseLine: true,
+		Short:                 "release the emergency stop, the token defaults to ESTOP_RESET_TOKEN",
+		Run:                   cmd.Run,
+	}
+}
+
+func (cmd *EStopReset) Run(_ *cobra.Command, args []string) {
+
+	token := os.Getenv("ESTOP_RESET_TOKEN")
+	if len(args) > 0 {
+		token = args[0]
+	}
+
+	gatherEStopStatus(consts.MQEStopResetChannel, new(messages.EStopReset).Init(token))
+}
@@ -0,0 +1,33 @@
+package robot
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+type EStopStatus struct {
+}
+
+func (cmd *EStopStatus) Init() *EStopStatus {
+	*cmd = EStopStatus{}
+
+	return cmd
+}
+
+func (cmd *EStopStatus) Command() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "estopStatus",
+		Aliases:               []string{"ess"},
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Short:                 "show the emergency stop state of every node",
+		Run:                   cmd.Run,
+	}
+}
+
+func (cmd *EStopStatus) Run(_ *cobra.Command, _ []string) {
+
+	gatherEStopStatus(consts.MQEStopGetChannel, new(messages.Query).Init())
+}
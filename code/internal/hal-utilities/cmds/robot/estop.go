@@ -0,0 +1,99 @@
+package robot
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+)
+
+type EStop struct {
+}
+
+func (cmd *EStop) Init() *EStop {
+	*cmd = EStop{}
+
+	return cmd
+}
+
+func (cmd *EStop) Command() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "estop",
+		Aliases:               []string{"es"},
+		Args:                  cobra.ArbitraryArgs,
+		ArgAliases:            []string{"reason"},
+		DisableFlagsInUseLine: true,
+		Short:                 "engage the emergency stop on every node",
+		Run:                   cmd.Run,
+	}
+}
+
+func (cmd *EStop) Run(_ *cobra.Command, args []string) {
+
+	reason := strings.Join(args, " ")
+	if reason == "" {
+		reason = "triggered from hal-utilities"
+	}
+
+	gatherEStopStatus(consts.MQEStopChannel, new(messages.EStop).Init(reason))
+}
+
+// gatherEStopStatus sends the request to every node and logs the emergency stop status
+// each of them replies with
+func gatherEStopStatus(channel string, request interface{}) {
+
+	nats, err := connect()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	defer nats.Close()
+
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	replies := 0
+
+	err = nats.GatherEStopStatus(ctx, channel, request, gatherIdle,
+		func(sender string, status *messages.EStopStatus, err error) error {
+			replies = replies + 1
+			return printEStopStatus(sender, status, err)
+		})
+
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	if replies == 0 {
+		logrus.Error("no node replied")
+	}
+}
+
+func printEStopStatus(sender string, status *messages.EStopStatus, err error) error {
+	var remoteError *mq.RemoteError
+
+	if errors.As(err, &remoteError) {
+		logrus.Errorf("%s: %s", sender, remoteError.Result.Text)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !status.Engaged {
+		logrus.Infof("%s: released", status.Node)
+		return nil
+	}
+
+	logrus.Warnf("%s: engaged by %s: %s", status.Node, status.Source, status.Reason)
+
+	return nil
+}
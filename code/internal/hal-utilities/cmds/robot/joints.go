@@ -0,0 +1,61 @@
+package robot
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+type Joints struct {
+}
+
+func (cmd *Joints) Init() *Joints {
+	*cmd = Joints{}
+
+	return cmd
+}
+
+func (cmd *Joints) Command() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "joints",
+		Aliases:               []string{"j"},
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Short:                 "show the last commanded joint and servo state",
+		Run:                   cmd.Run,
+	}
+}
+
+func (cmd *Joints) Run(_ *cobra.Command, _ []string) {
+
+	nats, err := connect()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	defer nats.Close()
+
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	jointState, err := nats.RequestJointState(ctx, consts.MQJointGetChannel, new(messages.Query).Init())
+
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	logrus.Infof("Left front: %+v", jointState.Joints.LeftFront)
+	logrus.Infof("Right front: %+v", jointState.Joints.RightFront)
+	logrus.Infof("Left back: %+v", jointState.Joints.LeftBack)
+	logrus.Infof("Right back: %+v", jointState.Joints.RightBack)
+
+	for _, servoState := range jointState.Servos {
+		logrus.Infof("Servo %d %s: joint %.3f rad, servo %.2f deg, ticks %d, resolution %.3f deg, clamped: %t, limited: %t",
+			servoState.PinId, servoState.Alias, servoState.JointAngle, servoState.Angle, servoState.Ticks, servoState.Resolution,
+			servoState.Clamped, servoState.Limited)
+	}
+}
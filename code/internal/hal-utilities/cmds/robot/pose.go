@@ -0,0 +1,53 @@
+package robot
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+type Pose struct {
+}
+
+func (cmd *Pose) Init() *Pose {
+	*cmd = Pose{}
+
+	return cmd
+}
+
+func (cmd *Pose) Command() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "pose",
+		Aliases:               []string{"p"},
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Short:                 "show the current body pose",
+		Run:                   cmd.Run,
+	}
+}
+
+func (cmd *Pose) Run(_ *cobra.Command, _ []string) {
+
+	nats, err := connect()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	defer nats.Close()
+
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	pose, err := nats.RequestPose(ctx, consts.MQPoseGetChannel, new(messages.Query).Init())
+
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	logrus.Infof("Position x: %.3f, y: %.3f, z: %.3f", pose.X, pose.Y, pose.Z)
+	logrus.Infof("Orientation roll: %.3f, pitch: %.3f, yaw: %.3f", pose.Roll, pose.Pitch, pose.Yaw)
+}
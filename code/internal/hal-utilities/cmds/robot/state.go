@@ -0,0 +1,59 @@
+package robot
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+type State struct {
+}
+
+func (cmd *State) Init() *State {
+	*cmd = State{}
+
+	return cmd
+}
+
+func (cmd *State) Command() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "state",
+		Aliases:               []string{"s"},
+		Args:                  cobra.MaximumNArgs(1),
+		ArgAliases:            []string{"target"},
+		DisableFlagsInUseLine: true,
+		Short:                 "show the controller state or move to powered-off, resting, standing or sitting",
+		Run:                   cmd.Run,
+	}
+}
+
+func (cmd *State) Run(_ *cobra.Command, args []string) {
+
+	nats, err := connect()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	defer nats.Close()
+
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	var robotState *messages.RobotState
+
+	if len(args) > 0 {
+		robotState, err = nats.RequestRobotState(ctx, consts.MQStateSetChannel, new(messages.StateRequest).Init(args[0]))
+	} else {
+		robotState, err = nats.RequestRobotState(ctx, consts.MQStateGetChannel, new(messages.Query).Init())
+	}
+
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	logrus.Infof("State: %s, target: %s, progress: %.0f%%", robotState.State, robotState.Target, robotState.Progress*100)
+}
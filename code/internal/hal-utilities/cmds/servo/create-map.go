@@ -13,6 +13,7 @@ import (
 	"github.com/spf13/cobra"
 
 	components "github.com/r4stl1n/micro-hal/code/pkg/components"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
 	drivers "github.com/r4stl1n/micro-hal/code/pkg/drivers"
 	base "github.com/r4stl1n/micro-hal/code/pkg/drivers/base"
 	"github.com/r4stl1n/micro-hal/code/pkg/structs"
@@ -202,6 +203,61 @@ func (cmd *CreateMap) moveToDefault(pca *drivers.PCA9685, servoId int, actuation
 	servo.Angle(angle)
 }
 
+// promptMapping asks which joint the servo drives and how the joint angle maps onto the servo angle
+func (cmd *CreateMap) promptMapping(defaultPosition int) structs.ServoJointMapping {
+	mapping := structs.ServoJointMapping{
+		ZeroOffset: float32(defaultPosition),
+		Direction:  1,
+		GearRatio:  1,
+	}
+
+	fmt.Printf("Please enter the leg of the servo (%s, %s, %s, %s):", consts.LegLeftFront, consts.LegRightFront,
+		consts.LegLeftBack, consts.LegRightBack)
+	fmt.Scanf("%s", &mapping.Leg)
+
+	fmt.Printf("Please enter the joint of the servo (%s, %s, %s):", consts.JointHip, consts.JointUpper,
+		consts.JointLower)
+	fmt.Scanf("%s", &mapping.Joint)
+
+	fmt.Print("Please enter the servo angle when the joint is at 0 radians:")
+	fmt.Scanf("%f", &mapping.ZeroOffset)
+
+	fmt.Print("Please enter the direction of the servo, 1 or -1 when it turns against the joint:")
+	fmt.Scanf("%d", &mapping.Direction)
+
+	fmt.Print("Please enter the gear ratio between the servo and the joint:")
+	fmt.Scanf("%f", &mapping.GearRatio)
+
+	return mapping
+}
+
+// promptSoftLimits asks for the safe servo angle range, it is asked again until the range
+// fits the actuation range and holds the default position
+func (cmd *CreateMap) promptSoftLimits(alias string, actuationRange int, defaultPosition int) (float32, float32) {
+	for {
+		minAngle := float32(0)
+		fmt.Print("Please enter the lowest servo angle that does not hit the chassis or the other joints:")
+		fmt.Scanf("%f", &minAngle)
+
+		maxAngle := float32(actuationRange)
+		fmt.Print("Please enter the highest servo angle that does not hit the chassis or the other joints:")
+		fmt.Scanf("%f", &maxAngle)
+
+		switch {
+		case minAngle < 0 || maxAngle > float32(actuationRange) || minAngle >= maxAngle:
+			fmt.Printf("Invalid soft limits %.2f to %.2f, they must be within 0 to %d\n", minAngle, maxAngle,
+				actuationRange)
+
+		case float32(defaultPosition) < minAngle || float32(defaultPosition) > maxAngle:
+			fmt.Printf("Invalid soft limits %.2f to %.2f, the default position %d of %s must be within them\n",
+				minAngle, maxAngle, defaultPosition, alias)
+
+		default:
+			return minAngle, maxAngle
+		}
+	}
+}
+
 func (cmd *CreateMap) Run(_ *cobra.Command, args []string) {
 
 	servoCount, minImpulse, maxImpulse, step, err := cmd.getConveretedValues(args)
@@ -250,6 +306,10 @@ func (cmd *CreateMap) Run(_ *cobra.Command, args []string) {
 
 		logrus.Infof("Min impulse is: %f, Max Impulse is: %f", newMinImpulse, newMaxImpulse)
 
+		minAngle, maxAngle := cmd.promptSoftLimits(servoAlias, actuationRange, defaultPosition)
+
+		mapping := cmd.promptMapping(defaultPosition)
+
 		servoMap.Servos = append(servoMap.Servos, structs.ServoCalibrationItem{
 			Alias:           servoAlias,
 			PinId:           servoId,
@@ -257,15 +317,23 @@ func (cmd *CreateMap) Run(_ *cobra.Command, args []string) {
 			MinPulse:        newMinImpulse,
 			MaxPulse:        newMaxImpulse,
 			DefaultPosition: defaultPosition,
+			MinAngle:        minAngle,
+			MaxAngle:        maxAngle,
+			Mapping:         mapping,
 		})
 
 		cmd.moveToDefault(pca, servoId, actuationRange, newMinImpulse, newMaxImpulse, defaultPosition)
 	}
 
+	// The joints-node refuses to start with a map it can not use
+	if _, err := new(components.JointMapper).Init(servoMap, true); err != nil {
+		logrus.Errorf("The servo map can not be used by the joints-node: %s", err)
+	}
+
 	marshaled, err := json.MarshalIndent(servoMap, "", " ")
 
 	if err != nil {
-		logrus.Fatal("Failed to create servo map from data: %s", err)
+		logrus.Fatalf("Failed to create servo map from data: %s", err)
 	}
 
 	fmt.Println(string(marshaled))
@@ -273,9 +341,10 @@ func (cmd *CreateMap) Run(_ *cobra.Command, args []string) {
 	err = ioutil.WriteFile(args[5], marshaled, 0644)
 
 	if err != nil {
-		logrus.Fatal("Could not write file: %s", err.Error())
+		logrus.Fatalf("Could not write file: %s", err.Error())
 	}
 
 	logrus.Infof("Servo map saved to: %s", args[5])
+	logrus.Info("Joint constraints are not part of the guided calibration, add them to the Constraints of the map")
 
 }
@@ -1,6 +1,11 @@
 package servos
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"strconv"
 
 	"github.com/sirupsen/logrus"
@@ -9,9 +14,12 @@ import (
 	components "github.com/r4stl1n/micro-hal/code/pkg/components"
 	drivers "github.com/r4stl1n/micro-hal/code/pkg/drivers"
 	base "github.com/r4stl1n/micro-hal/code/pkg/drivers/base"
+	structs "github.com/r4stl1n/micro-hal/code/pkg/structs"
 )
 
 type Move struct {
+	servoMapPath string
+	force        bool
 }
 
 func (cmd *Move) Init() *Move {
@@ -21,18 +29,27 @@ func (cmd *Move) Init() *Move {
 }
 
 func (cmd *Move) Command() *cobra.Command {
-	return &cobra.Command{
+	command := &cobra.Command{
 		Use:                   "move",
 		Aliases:               []string{"m"},
 		Args:                  cobra.ExactArgs(6),
 		ArgAliases:            []string{"i2c-address", "servo_id", "actuationRange", "min_impusle", "max_impulse", "angle"},
 		DisableFlagsInUseLine: true,
-		Short:                 "move servo",
+		Short:                 "move servo, refused outside the soft limits of the servo in the servo map",
 		Run:                   cmd.Run,
 	}
+
+	command.Flags().StringVar(&cmd.servoMapPath, "map", "./ServoMap.json", "servo map holding the soft limits")
+	command.Flags().BoolVar(&cmd.force, "force", false, "move past the soft limits or without a servo map")
+	command.Flags().BoolVar(&cmd.force, "no-limits", false, "same as --force")
+
+	// Kept so existing scripts keep working, it sets the same override as --force
+	_ = command.Flags().MarkDeprecated("no-limits", "use --force instead")
+
+	return command
 }
 
-func (cmd *Move) getConveretedValues(args []string) (int, int, float32, float32, int, error) {
+func (cmd *Move) getConveretedValues(args []string) (int, int, float32, float32, float32, error) {
 	// Need to covert our current arguments into values
 	servoId, err := strconv.Atoi(args[1])
 
@@ -58,17 +75,57 @@ func (cmd *Move) getConveretedValues(args []string) (int, int, float32, float32,
 		return 0, 0, 0, 0, 0.0, err
 	}
 
-	angle, err := strconv.Atoi(args[5])
+	angle, err := strconv.ParseFloat(args[5], 32)
 
 	if err != nil {
 		return 0, 0, 0, 0, 0.0, err
 	}
 
-	return servoId, actuationRange, float32(minImpulse), float32(maxImpulse), angle, nil
+	return servoId, actuationRange, float32(minImpulse), float32(maxImpulse), float32(angle), nil
 
 }
 
-func (cmd *Move) Run(_ *cobra.Command, args []string) {
+// checkSoftLimits refuses angles outside the soft limits the servo map holds for the pin,
+// moves are also refused when the limits are unknown, a servo without a MaxAngle has no
+// limits recorded. Joint constraints are not checked as the angles of the other joints
+// are unknown here
+func (cmd *Move) checkSoftLimits(servoId int, angle float32) error {
+	servoMapData, err := ioutil.ReadFile(cmd.servoMapPath)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no servo map at %s to check the soft limits", cmd.servoMapPath)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	servoMap := structs.ServoCalibrationMap{}
+
+	if err := json.Unmarshal(servoMapData, &servoMap); err != nil {
+		return err
+	}
+
+	for _, item := range servoMap.Servos {
+		if item.PinId != servoId {
+			continue
+		}
+
+		if item.MaxAngle == 0 {
+			return fmt.Errorf("servo %s has no soft limits in the servo map %s", item.Alias, cmd.servoMapPath)
+		}
+
+		return components.CheckSoftLimits(item, angle)
+	}
+
+	return fmt.Errorf("servo %d is not in the servo map %s to check the soft limits", servoId, cmd.servoMapPath)
+}
+
+func (cmd *Move) Run(command *cobra.Command, args []string) {
+
+	// The interactive shell reuses the command, only honour the override for this move
+	force := cmd.force
+	cmd.force = false
 
 	servoId, actuationRange, minImpulse, maxImpulse, angle, err := cmd.getConveretedValues(args)
 
@@ -76,6 +133,12 @@ func (cmd *Move) Run(_ *cobra.Command, args []string) {
 		logrus.Fatal(err)
 	}
 
+	if force {
+		logrus.Warn("Soft limits are overridden, make sure the servo is free to move")
+	} else if err := cmd.checkSoftLimits(servoId, angle); err != nil {
+		logrus.Fatalf("%s, use --force to move anyway", err)
+	}
+
 	// We create a connection to the i2c interface on the raspberry pi
 	logrus.Infof("Attempting to connect to the i2c address: %s", args[0])
 	i2c, err := new(base.I2C).Init(drivers.DefaultPCA9685Address, args[0], base.DEFAULT_I2C_ADDRESS)
@@ -97,9 +160,11 @@ func (cmd *Move) Run(_ *cobra.Command, args []string) {
 		MaxPulse:       maxImpulse,
 	})
 
-	logrus.Infof("Sending the servo move command for angle: %d", angle)
+	logrus.Infof("Sending the servo move command for angle: %.2f, resolution: %.3f degrees", angle, servo.Resolution())
 
 	// Move the servo to a specific angle
-	servo.Angle(angle)
+	if err := servo.Degrees(angle); err != nil {
+		logrus.Fatal(err)
+	}
 
 }
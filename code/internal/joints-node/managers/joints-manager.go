@@ -2,6 +2,9 @@ package managers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/r4stl1n/micro-hal/code/internal/joints-node/sim"
 	"github.com/r4stl1n/micro-hal/code/pkg/components"
 	"github.com/r4stl1n/micro-hal/code/pkg/consts"
 	"github.com/r4stl1n/micro-hal/code/pkg/drivers"
@@ -11,27 +14,64 @@ import (
 	"github.com/r4stl1n/micro-hal/code/pkg/structs"
 	"github.com/sirupsen/logrus"
 	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
 )
 
 type JointsManager struct {
-	nats *mq.Nats
+	nats   *mq.Nats
+	config structs.JointsConfig
 
-	baseI2CConn *base.I2C
+	baseI2CConn base.Device
 	pcaDriver   *drivers.PCA9685
+	simBank     *sim.ServoBank
 
 	servoMap                   map[string]*components.Servo
-	currentJointsPosition      messages.Joints
+	servoMotion                *components.ServoMotion
+	jointMapper                *components.JointMapper
 	defaultServoCalibrationMap structs.ServoCalibrationMap
+
+	stateMutex            sync.Mutex
+	currentJointsPosition messages.Joints
+	servoStates           map[string]messages.ServoState
+
+	eStopLatch *mq.EStopLatch
+
+	stopChannel chan struct{}
+	stopOnce    sync.Once
 }
 
-func (jointsManager *JointsManager) Init() (*JointsManager, error) {
+func (jointsManager *JointsManager) Init(natsConfig structs.NatsConfig, config structs.JointsConfig) (*JointsManager, error) {
+
+	if natsConfig.Name == "" {
+		natsConfig.Name = consts.NodeNameJoints
+	}
+
+	motionProfile, err := components.ParseMotionProfile(config.MotionProfile)
+
+	if err != nil {
+		return nil, err
+	}
 
 	*jointsManager = JointsManager{
-		nats:     new(mq.Nats).Init(*new(structs.NatsConfig).Defaults()),
-		servoMap: map[string]*components.Servo{},
+		nats:        new(mq.Nats).Init(natsConfig),
+		config:      config,
+		servoMap:    map[string]*components.Servo{},
+		servoStates: map[string]messages.ServoState{},
+		stopChannel: make(chan struct{}),
 	}
 
-	err := jointsManager.connectI2C()
+	jointsManager.eStopLatch = new(mq.EStopLatch).Init(jointsManager.nats, config.EStopResetToken,
+		jointsManager.cutPower, nil)
+
+	jointsManager.servoMotion = new(components.ServoMotion).Init(components.MotionLimits{
+		MaxVelocity:     config.MotionMaxVelocity,
+		MaxAcceleration: config.MotionMaxAcceleration,
+		Profile:         motionProfile,
+	}, config.MotionRate)
+
+	err = jointsManager.connectI2C()
 
 	if err != nil {
 		return nil, err
@@ -42,10 +82,29 @@ func (jointsManager *JointsManager) Init() (*JointsManager, error) {
 	return jointsManager, err
 }
 
+func (jointsManager *JointsManager) openBus() (base.Bus, error) {
+	switch jointsManager.config.Backend {
+	case structs.JointsBackendHardware:
+		// We create a connection to the i2c interface on the raspberry pi
+		logrus.Infof("Attempting to connect to the i2c address: %s", jointsManager.config.I2CDevice)
+		return new(base.DevBus).Init(jointsManager.config.I2CDevice), nil
+
+	case structs.JointsBackendSim:
+		logrus.Info("Using the simulated servo bank")
+		return new(base.FakeBus).Init(), nil
+
+	default:
+		return nil, fmt.Errorf("unknown joints backend: %s", jointsManager.config.Backend)
+	}
+}
+
 func (jointsManager *JointsManager) connectI2C() error {
-	// We create a connection to the i2c interface on the raspberry pi
-	logrus.Infof("Attempting to connect to the i2c address: /dev/i2c-1")
-	i2c, err := new(base.I2C).Init(drivers.DefaultPCA9685Address, "/dev/i2c-1", base.DEFAULT_I2C_ADDRESS)
+	bus, err := jointsManager.openBus()
+	if err != nil {
+		return err
+	}
+
+	i2c, err := bus.Open(drivers.DefaultPCA9685Address)
 	if err != nil {
 		return err
 	}
@@ -62,6 +121,11 @@ func (jointsManager *JointsManager) connectI2C() error {
 
 	jointsManager.pcaDriver = pca
 
+	if fakeBus, ok := bus.(*base.FakeBus); ok {
+		jointsManager.simBank = new(sim.ServoBank).Init(jointsManager.nats, fakeBus.Device(drivers.DefaultPCA9685Address),
+			pca.GetFreq(), jointsManager.config)
+	}
+
 	return nil
 
 }
@@ -69,7 +133,7 @@ func (jointsManager *JointsManager) connectI2C() error {
 func (jointsManager *JointsManager) loadServoMap() error {
 
 	// Attempt to load the servo map
-	servoMapData, err := ioutil.ReadFile("./ServoMap.json")
+	servoMapData, err := ioutil.ReadFile(jointsManager.config.ServoMapPath)
 
 	if err != nil {
 		return err
@@ -88,69 +152,324 @@ func (jointsManager *JointsManager) loadServoMap() error {
 			MaxPulse:       element.MaxPulse,
 		})
 
-		jointsManager.servoMap[element.Alias].Angle(element.DefaultPosition)
+		jointsManager.servoStates[element.Alias] = messages.ServoState{
+			Alias:      element.Alias,
+			PinId:      element.PinId,
+			JointAngle: components.FromServoAngle(element, float32(element.DefaultPosition)),
+			Angle:      float32(element.DefaultPosition),
+		}
+	}
+
+	if jointsManager.config.IgnoreSoftLimits {
+		logrus.Warn("soft limits and joint constraints are ignored, joint commands can drive the servos into each other")
+	}
+
+	jointsManager.jointMapper, err = new(components.JointMapper).Init(jointsManager.defaultServoCalibrationMap,
+		!jointsManager.config.IgnoreSoftLimits)
+
+	if jointsManager.simBank != nil {
+		jointsManager.simBank.SetCalibration(jointsManager.defaultServoCalibrationMap)
 	}
 
 	return err
 }
 
+// startupMove eases every servo from the angle saved on the last shutdown to its default
+// position. Servos without a saved angle are driven straight to the default position
+func (jointsManager *JointsManager) startupMove() error {
+
+	savedAngles := map[string]float32{}
+
+	stateData, err := ioutil.ReadFile(jointsManager.config.StatePath)
+
+	if err == nil {
+		err = json.Unmarshal(stateData, &savedAngles)
+	}
+
+	if err != nil {
+		logrus.Warnf("servo positions unknown, moving straight to the default positions: %s", err)
+	}
+
+	targets := map[*components.Servo]float32{}
+
+	for _, element := range jointsManager.defaultServoCalibrationMap.Servos {
+		servo := jointsManager.servoMap[element.Alias]
+
+		if angle, ok := savedAngles[element.Alias]; ok {
+			jointsManager.servoMotion.SetPosition(servo, angle)
+		}
+
+		target := float32(element.DefaultPosition)
+
+		if !jointsManager.config.IgnoreSoftLimits {
+			if limited, ok := components.ClampSoftLimits(element, target); ok {
+				logrus.Warnf("default position %d of servo %s is outside its soft limits, moving to %.2f degrees",
+					element.DefaultPosition, element.Alias, limited)
+				target = limited
+			}
+		}
+
+		targets[servo] = target
+	}
+
+	return jointsManager.servoMotion.MoveGroup(targets)
+}
+
+// saveServoState writes the angle of every servo that is still powered so the next
+// startup move can start from it
+func (jointsManager *JointsManager) saveServoState() error {
+
+	angles := map[string]float32{}
+
+	for alias, servo := range jointsManager.servoMap {
+		if angle, ok := jointsManager.servoMotion.Position(servo); ok && servo.Ticks() != 0 {
+			angles[alias] = angle
+		}
+	}
+
+	stateData, err := json.MarshalIndent(angles, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(jointsManager.config.StatePath, stateData, 0644)
+}
+
 func (jointsManager *JointsManager) connectToNats() error {
 	return jointsManager.nats.Connect()
 }
 
-func (jointsManager *JointsManager) HandleJointsMessage(joints *messages.Joints) {
+// HandleJointsMessage moves the servos to the joints, it is refused while the emergency
+// stop is engaged. The joints are written straight away without the motion limits, the
+// controller streams them at its loop rate along its own trajectories and easing them
+// again would only make the servos lag behind the gait
+func (jointsManager *JointsManager) HandleJointsMessage(joints *messages.Joints) error {
+
+	jointsManager.stateMutex.Lock()
+	defer jointsManager.stateMutex.Unlock()
+
+	// Checked under the state mutex so no command can move a servo after cutPower
+	if eStopError := jointsManager.eStopLatch.Check(); eStopError != nil {
+		return eStopError
+	}
+
+	// Every servo is written in a single transaction so the legs move in the same pwm cycle
+	frame := new(components.ServoFrame).Init()
+	commands := make([]components.ServoCommand, 0, len(jointsManager.servoMap))
+
+	for _, command := range jointsManager.jointMapper.Map(joints) {
+		if command.Clamped {
+			logrus.Warnf("joint angle %f for servo %s is out of range, clamped to %f degrees",
+				command.JointAngle, command.Alias, command.Angle)
+		} else if command.Limited {
+			logrus.Warnf("joint angle %f for servo %s is outside its soft limits or joint constraints, limited to %f degrees",
+				command.JointAngle, command.Alias, command.Angle)
+		}
+
+		angleError := frame.Degrees(jointsManager.servoMap[command.Alias], command.Angle)
+		if angleError != nil {
+			logrus.Errorf("failed to move servo %s: %s", command.Alias, angleError)
+			continue
+		}
+
+		// Joint commands take over from a running move of the servo
+		jointsManager.servoMotion.SetPosition(jointsManager.servoMap[command.Alias], command.Angle)
+
+		commands = append(commands, command)
+	}
+
+	if commitError := frame.Commit(); commitError != nil {
+		for _, command := range commands {
+			jointsManager.servoMotion.Forget(jointsManager.servoMap[command.Alias])
+		}
+
+		return fmt.Errorf("failed to move the servos: %w", commitError)
+	}
+
+	for _, command := range commands {
+		servoState := jointsManager.servoStates[command.Alias]
+		servoState.JointAngle = command.JointAngle
+		servoState.Angle = command.Angle
+		servoState.Clamped = command.Clamped
+		servoState.Limited = command.Limited
+
+		jointsManager.servoStates[command.Alias] = servoState
+	}
+
+	jointsManager.currentJointsPosition = *joints
+
+	return nil
+}
+
+// cutPower turns off the pwm output of every pca9685 channel so the servos go limp, the
+// servos stay off until the next joint command after the emergency stop is released
+func (jointsManager *JointsManager) cutPower(_ *messages.EStopStatus) {
+
+	jointsManager.stateMutex.Lock()
+	defer jointsManager.stateMutex.Unlock()
+
+	for _, servo := range jointsManager.servoMap {
+		jointsManager.servoMotion.Forget(servo)
+	}
+
+	// A single write to the ALL_LED registers also turns off the channels without a mapped servo
+	if channelError := jointsManager.pcaDriver.SetAllChannels(0, 0); channelError != nil {
+		logrus.Errorf("failed to turn off the pca9685 channels: %s", channelError)
+		return
+	}
+
+	logrus.Warn("pwm output of all channels turned off")
+}
+
+// JointState returns the last commanded joints and the state of every servo ordered by pin
+func (jointsManager *JointsManager) JointState() *messages.JointState {
+
+	jointsManager.stateMutex.Lock()
+	defer jointsManager.stateMutex.Unlock()
+
+	jointState := new(messages.JointState).Init()
+	jointState.Joints = jointsManager.currentJointsPosition
+
+	for alias, servoState := range jointsManager.servoStates {
+		servoState.Ticks = jointsManager.servoMap[alias].Ticks()
+		servoState.Resolution = jointsManager.servoMap[alias].Resolution()
+		jointState.Servos = append(jointState.Servos, servoState)
+	}
+
+	sort.Slice(jointState.Servos, func(i, j int) bool {
+		return jointState.Servos[i].PinId < jointState.Servos[j].PinId
+	})
+
+	return jointState
+}
+
+func (jointsManager *JointsManager) handleJoints(request *mq.Request, _ *mq.Responder) (interface{}, error) {
+	joints := new(messages.Joints)
+
+	unpackError := request.Unpack(joints)
+	if unpackError != nil {
+		return nil, unpackError
+	}
+
+	return nil, jointsManager.HandleJointsMessage(joints)
+}
+
+// publishTelemetry publishes the joint state at the configured rate until the stop
+// channel is closed
+func (jointsManager *JointsManager) publishTelemetry(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(float32(time.Second) / jointsManager.config.TelemetryRate))
+	defer ticker.Stop()
 
+	logrus.Infof("publishing joint state at %.1f Hz", jointsManager.config.TelemetryRate)
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-ticker.C:
+			publishError := jointsManager.nats.Publish(consts.MQJointStateChannel, jointsManager.JointState())
+			if publishError != nil && !errors.Is(publishError, mq.ErrDisconnected) {
+				logrus.Error(publishError)
+			}
+		}
+	}
+}
+
+func (jointsManager *JointsManager) handleJointsGet(_ *mq.Request, _ *mq.Responder) (interface{}, error) {
+	return jointsManager.JointState(), nil
+}
+
+// Stop stops the simulated servo bank when running and makes Process return
+func (jointsManager *JointsManager) Stop() {
+	jointsManager.stopOnce.Do(func() {
+		close(jointsManager.stopChannel)
+	})
+}
+
+func (jointsManager *JointsManager) handleConnectionEvent(event mq.ConnectionEvent) {
+	switch event.State {
+	case mq.StateDisconnected:
+		logrus.Warn("lost the connection to nats, holding the servos at their last position")
+
+	case mq.StateReconnected:
+		logrus.Info("connection to nats restored")
+
+	case mq.StateClosed:
+		logrus.Error("connection to nats closed, stopping")
+		jointsManager.Stop()
+	}
 }
 
 func (jointsManager *JointsManager) Process() error {
 
+	go jointsManager.servoMotion.Run(jointsManager.stopChannel, func(err error) {
+		logrus.Errorf("failed to step servo motion: %s", err)
+	})
+
+	startupError := jointsManager.startupMove()
+	if startupError != nil {
+		return startupError
+	}
+
+	defer func() {
+		if saveError := jointsManager.saveServoState(); saveError != nil {
+			logrus.Errorf("failed to save the servo state: %s", saveError)
+		}
+	}()
+
+	jointsManager.nats.OnConnectionEvent(jointsManager.handleConnectionEvent)
+
 	connectToNatsError := jointsManager.connectToNats()
 
 	if connectToNatsError != nil {
 		return connectToNatsError
 	}
 
-	receiveChannel := make(chan *[]byte, 100)
-	_, bindError := jointsManager.nats.EncodedConn.BindRecvChan(consts.MQPoseSetChannel, receiveChannel)
-	if bindError != nil {
-		return bindError
+	if jointsManager.simBank != nil {
+		go jointsManager.simBank.Run(jointsManager.stopChannel)
 	}
 
-	_, bindError = jointsManager.nats.EncodedConn.BindRecvChan(consts.MQJointSetChannel, receiveChannel)
-	if bindError != nil {
-		return bindError
+	if jointsManager.config.TelemetryRate > 0 {
+		go jointsManager.publishTelemetry(jointsManager.stopChannel)
 	}
 
-	logrus.Info("service started waiting for messages")
+	// Emergency stops get their own service so they are not queued behind joint commands
+	eStopService := new(mq.Service).Init(jointsManager.nats, 1)
 
-	for {
-		select {
-		case receiveData := <-receiveChannel:
-			requestMessage := new(messages.Message)
-			requestError := requestMessage.Unpack(*receiveData)
-			if requestError != nil {
-				logrus.Error(requestError)
-				continue
-			}
+	registerError := jointsManager.eStopLatch.Register(eStopService)
+	if registerError != nil {
+		return registerError
+	}
 
-			logrus.Debugf("Received Message: %+v", requestMessage)
+	eStopDone := make(chan struct{})
 
-			switch requestMessage.Type {
+	go func() {
+		eStopService.Run(jointsManager.stopChannel)
+		close(eStopDone)
+	}()
 
-			case messages.JointsMessage:
-				sMessage := new(messages.Joints)
+	service := new(mq.Service).Init(jointsManager.nats, 1)
+	service.Handle(messages.JointsMessage, jointsManager.handleJoints)
+	service.Handle(messages.QueryMessage, jointsManager.handleJointsGet)
+	service.Filter(new(mq.CommandFilter).Init(jointsManager.config.CommandMaxAge), messages.JointsMessage)
 
-				unpackError := sMessage.Unpack(requestMessage.Data)
-				if unpackError != nil {
-					logrus.Error(unpackError)
-					continue
-				}
+	for _, channel := range []string{consts.MQJointSetChannel, consts.MQJointGetChannel} {
+		subscribeError := service.Subscribe(channel)
+		if subscribeError != nil {
+			return subscribeError
+		}
+	}
 
-				jointsManager.HandleJointsMessage(sMessage)
-			default:
-				logrus.Errorf("unknown message received %v+", requestMessage)
-			}
+	logrus.Info("service started waiting for messages")
 
-		}
+	service.Run(jointsManager.stopChannel)
+	<-eStopDone
+
+	if jointsManager.nats.Closed() {
+		return mq.ErrConnectionClosed
 	}
+
+	return nil
 }
@@ -0,0 +1,250 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	math "github.com/chewxy/math32"
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+const testServoMap = "../../../builds/ServoMap.json"
+
+// jointsFixture runs a joints-node with the sim backend and the shipped servo map on an
+// in-process nats server
+type jointsFixture struct {
+	client  *mq.Nats
+	manager *JointsManager
+}
+
+func testJointsConfig(t *testing.T) structs.JointsConfig {
+	config := *new(structs.JointsConfig).Defaults()
+	config.Backend = structs.JointsBackendSim
+	config.ServoMapPath = testServoMap
+	config.StatePath = filepath.Join(t.TempDir(), "ServoState.json")
+	config.TelemetryRate = 50
+	config.CommandMaxAge = 0
+	config.EStopResetToken = "reset"
+
+	return config
+}
+
+func startJointsNode(t *testing.T, config structs.JointsConfig) *jointsFixture {
+	server := new(mq.Server).Init(structs.NatsConfig{Host: "127.0.0.1:-1"})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(server.Shutdown)
+
+	natsConfig := structs.NatsConfig{Host: server.Addr()}
+
+	manager, err := new(JointsManager).Init(natsConfig, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- manager.Process()
+	}()
+
+	t.Cleanup(func() {
+		manager.Stop()
+
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	natsConfig.Name = "test"
+	client := new(mq.Nats).Init(natsConfig)
+
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(client.Close)
+
+	fixture := &jointsFixture{client: client, manager: manager}
+
+	// Process connects after the startup move, wait until the node answers
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		if _, err := fixture.jointState(); err == nil {
+			return fixture
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("joints-node did not start")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (fixture *jointsFixture) jointState() (*messages.JointState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	return fixture.client.RequestJointState(ctx, consts.MQJointGetChannel, new(messages.Query).Init())
+}
+
+func (fixture *jointsFixture) setJoints(joints *messages.Joints) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := fixture.client.RequestResult(ctx, consts.MQJointSetChannel, joints)
+
+	return err
+}
+
+// subscribe returns the joint states published on the channel
+func (fixture *jointsFixture) subscribe(t *testing.T, channel string) chan *nats.Msg {
+	published := make(chan *nats.Msg, 100)
+
+	if _, err := fixture.client.Conn.ChanSubscribe(channel, published); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fixture.client.Conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	return published
+}
+
+func unpackJointState(t *testing.T, msg *nats.Msg) *messages.JointState {
+	message := new(messages.Message)
+
+	if err := message.Unpack(msg.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	jointState := new(messages.JointState)
+
+	if err := jointState.Unpack(message.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	return jointState
+}
+
+// nominalJoints is the nominal stance of the default geometry
+func nominalJoints() *messages.Joints {
+	leg := hmath.Vec3{0, 0.7708, -1.3025}
+
+	return &messages.Joints{LeftFront: leg, RightFront: leg, LeftBack: leg, RightBack: leg}
+}
+
+func TestJointsNodePublishesTelemetry(t *testing.T) {
+	fixture := startJointsNode(t, testJointsConfig(t))
+	telemetry := fixture.subscribe(t, consts.MQJointStateChannel)
+
+	joints := nominalJoints()
+
+	if err := fixture.setJoints(joints); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(2 * time.Second)
+
+	for {
+		select {
+		case msg := <-telemetry:
+			jointState := unpackJointState(t, msg)
+
+			if jointState.Joints != *joints {
+				continue
+			}
+
+			if len(jointState.Servos) != 12 {
+				t.Fatalf("telemetry has %d servos, expected 12", len(jointState.Servos))
+			}
+
+			for i, servoState := range jointState.Servos {
+				if i > 0 && servoState.PinId <= jointState.Servos[i-1].PinId {
+					t.Fatalf("servos are not ordered by pin: %+v", jointState.Servos)
+				}
+
+				if servoState.Ticks == 0 || servoState.Resolution <= 0 {
+					t.Errorf("servo %s reports %d ticks at %f degrees per tick", servoState.Alias, servoState.Ticks,
+						servoState.Resolution)
+				}
+			}
+
+			// The nominal stance maps the left upper joints onto 150.53 - 44.16 degrees
+			for _, servoState := range jointState.Servos {
+				if servoState.Alias == "front-left-leg" && math.Abs(servoState.Angle-106.37) > 0.05 {
+					t.Errorf("front-left-leg at %f degrees, expected 106.37", servoState.Angle)
+				}
+			}
+
+			return
+
+		case <-timeout:
+			t.Fatal("telemetry never reported the commanded joints")
+		}
+	}
+}
+
+func TestJointsNodeTelemetryCanBeDisabled(t *testing.T) {
+	config := testJointsConfig(t)
+	config.TelemetryRate = 0
+
+	fixture := startJointsNode(t, config)
+	telemetry := fixture.subscribe(t, consts.MQJointStateChannel)
+
+	select {
+	case <-telemetry:
+		t.Fatal("joint state published with telemetry disabled")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// The joint state can still be queried
+	if _, err := fixture.jointState(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJointsNodeRefusesJointsWhileEStopped(t *testing.T) {
+	fixture := startJointsNode(t, testJointsConfig(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	status := new(messages.EStopStatus)
+
+	if err := fixture.client.Request(ctx, consts.MQEStopChannel, new(messages.EStop).Init("test"), status); err != nil ||
+		!status.Engaged {
+		t.Fatalf("emergency stop replied %+v: %v", status, err)
+	}
+
+	var remoteError *mq.RemoteError
+
+	if err := fixture.setJoints(nominalJoints()); !errors.As(err, &remoteError) ||
+		!strings.Contains(remoteError.Result.Text, mq.ErrEStopEngaged.Error()) {
+		t.Fatalf("joints while e-stopped returned %v, expected %v", err, mq.ErrEStopEngaged)
+	}
+
+	if err := fixture.client.Request(ctx, consts.MQEStopResetChannel, new(messages.EStopReset).Init("reset"), status); err != nil ||
+		status.Engaged {
+		t.Fatalf("reset replied %+v: %v", status, err)
+	}
+
+	if err := fixture.setJoints(nominalJoints()); err != nil {
+		t.Fatalf("joints refused after the reset: %s", err)
+	}
+}
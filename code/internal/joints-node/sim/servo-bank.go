@@ -0,0 +1,219 @@
+package sim
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/components"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	base "github.com/r4stl1n/micro-hal/code/pkg/drivers/base"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	pcaChannelCount = 16
+	pcaLed0On       = 0x06
+	pcaAllLedOn     = 0xFA
+	pcaStepCount    = 4096.0
+)
+
+// ServoChannel stores the simulated state of a single pca9685 channel
+type ServoChannel struct {
+	CommandedPulse float32 // pulse width in microseconds last written to the channel
+	CurrentPulse   float32 // pulse width in microseconds the servo has reached
+}
+
+// ServoBank simulates the servos attached to a pca9685 by watching the LEDn registers
+// of a fake i2c device. Servos move toward the commanded pulse width limited by the
+// configured slew rate and the resulting joint state is published over nats
+type ServoBank struct {
+	nats      *mq.Nats
+	device    *base.FakeDevice
+	frequency float32
+	config    structs.JointsConfig
+
+	mutex       sync.Mutex
+	channels    [pcaChannelCount]ServoChannel
+	calibration map[int]structs.ServoCalibrationItem
+}
+
+func (servoBank *ServoBank) Init(nats *mq.Nats, device *base.FakeDevice, frequency float32, config structs.JointsConfig) *ServoBank {
+	*servoBank = ServoBank{
+		nats:        nats,
+		device:      device,
+		frequency:   frequency,
+		config:      config,
+		calibration: map[int]structs.ServoCalibrationItem{},
+	}
+
+	device.OnWrite = servoBank.onWrite
+
+	return servoBank
+}
+
+// SetCalibration sets the calibration used to convert pulse widths into angles
+func (servoBank *ServoBank) SetCalibration(calibrationMap structs.ServoCalibrationMap) {
+	servoBank.mutex.Lock()
+	defer servoBank.mutex.Unlock()
+
+	for _, item := range calibrationMap.Servos {
+		servoBank.calibration[item.PinId] = item
+	}
+}
+
+// Channels returns a copy of the state of every channel
+func (servoBank *ServoBank) Channels() [pcaChannelCount]ServoChannel {
+	servoBank.mutex.Lock()
+	defer servoBank.mutex.Unlock()
+
+	return servoBank.channels
+}
+
+func (servoBank *ServoBank) onWrite(reg byte, _ byte) {
+	// The chip loads a write to the ALL_LED registers into the LEDn registers of every
+	// channel once the high byte of the off count has been written
+	if reg == pcaAllLedOn+3 {
+		for channel := 0; channel < pcaChannelCount; channel++ {
+			for i := byte(0); i < 4; i++ {
+				servoBank.device.SetRegister(pcaLed0On+byte(4*channel)+i, servoBank.device.Register(pcaAllLedOn+i))
+			}
+
+			servoBank.updateChannel(channel)
+		}
+
+		return
+	}
+
+	if reg < pcaLed0On || reg >= pcaLed0On+(4*pcaChannelCount) {
+		return
+	}
+
+	// Only update once the high byte of the off count has been written
+	offset := reg - pcaLed0On
+	if offset%4 != 3 {
+		return
+	}
+
+	servoBank.updateChannel(int(offset / 4))
+}
+
+// updateChannel sets the commanded pulse of the channel from its LEDn registers
+func (servoBank *ServoBank) updateChannel(channel int) {
+	offReg := pcaLed0On + byte(4*channel) + 2
+
+	off := int(servoBank.device.Register(offReg)) | int(servoBank.device.Register(offReg+1))<<8
+	pulse := float32(off) / pcaStepCount * (1000000.0 / servoBank.frequency)
+
+	servoBank.mutex.Lock()
+	defer servoBank.mutex.Unlock()
+
+	servoBank.channels[channel].CommandedPulse = pulse
+
+	// A servo that has never been driven jumps straight to its first command
+	if servoBank.channels[channel].CurrentPulse == 0 || pulse == 0 {
+		servoBank.channels[channel].CurrentPulse = pulse
+	}
+}
+
+// Step moves every channel toward its commanded pulse width
+func (servoBank *ServoBank) Step(dt float32) {
+	servoBank.mutex.Lock()
+	defer servoBank.mutex.Unlock()
+
+	for i := 0; i < pcaChannelCount; i++ {
+		channel := &servoBank.channels[i]
+
+		item, ok := servoBank.calibration[i]
+		if !ok || item.ActuationRange == 0 {
+			channel.CurrentPulse = channel.CommandedPulse
+			continue
+		}
+
+		pulsePerDegree := (item.MaxPulse - item.MinPulse) / float32(item.ActuationRange)
+		maxStep := servoBank.config.SimSlewRate * pulsePerDegree * dt
+
+		delta := channel.CommandedPulse - channel.CurrentPulse
+
+		if delta > maxStep {
+			delta = maxStep
+		} else if delta < -maxStep {
+			delta = -maxStep
+		}
+
+		channel.CurrentPulse = channel.CurrentPulse + delta
+	}
+}
+
+// JointState converts the current pulse width of every mapped servo into joint angles
+func (servoBank *ServoBank) JointState() *messages.Joints {
+	servoBank.mutex.Lock()
+	defer servoBank.mutex.Unlock()
+
+	joints := new(messages.Joints).Init()
+
+	legs := map[string]*hmath.Vec3{
+		consts.LegLeftFront:  &joints.LeftFront,
+		consts.LegRightFront: &joints.RightFront,
+		consts.LegLeftBack:   &joints.LeftBack,
+		consts.LegRightBack:  &joints.RightBack,
+	}
+
+	jointIndex := map[string]int{
+		consts.JointHip:   0,
+		consts.JointUpper: 1,
+		consts.JointLower: 2,
+	}
+
+	for pin, item := range servoBank.calibration {
+		leg, legOk := legs[item.Mapping.Leg]
+		index, jointOk := jointIndex[item.Mapping.Joint]
+
+		if !legOk || !jointOk || pin < 0 || pin >= pcaChannelCount || item.MaxPulse == item.MinPulse {
+			continue
+		}
+
+		// Channels that have never been driven report a zero joint angle
+		if servoBank.channels[pin].CurrentPulse == 0 {
+			continue
+		}
+
+		fraction := (servoBank.channels[pin].CurrentPulse - item.MinPulse) / (item.MaxPulse - item.MinPulse)
+		leg[index] = components.FromServoAngle(item, fraction*float32(item.ActuationRange))
+	}
+
+	return joints
+}
+
+// Run steps the simulation and publishes the joint state until the stop channel is closed
+func (servoBank *ServoBank) Run(stop <-chan struct{}) {
+	period := time.Duration(float32(time.Second) / servoBank.config.SimPublishRate)
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	logrus.Infof("simulated servo bank started at %.1f Hz with a slew rate of %.1f deg/s",
+		servoBank.config.SimPublishRate, servoBank.config.SimSlewRate)
+
+	lastStep := time.Now()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case stepTime := <-ticker.C:
+			servoBank.Step(float32(stepTime.Sub(lastStep).Seconds()))
+			lastStep = stepTime
+
+			publishError := servoBank.nats.Publish(consts.MQSimJointsChannel, servoBank.JointState())
+			if publishError != nil && !errors.Is(publishError, mq.ErrDisconnected) {
+				logrus.Error(publishError)
+			}
+		}
+	}
+}
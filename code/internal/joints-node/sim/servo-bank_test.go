@@ -0,0 +1,226 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	math "github.com/chewxy/math32"
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/pkg/components"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/drivers"
+	base "github.com/r4stl1n/micro-hal/code/pkg/drivers/base"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/mq"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+// simFixture drives a simulated servo bank through the pca9685 driver the same way the
+// joints-node does with the sim backend
+type simFixture struct {
+	bank        *ServoBank
+	servos      map[string]*components.Servo
+	jointMapper *components.JointMapper
+}
+
+// testServoMap maps the upper and lower joints of the left front leg and the hip of the
+// right back leg
+func testServoMap() structs.ServoCalibrationMap {
+	item := func(alias string, pin int, leg string, joint string, zeroOffset float32, direction int) structs.ServoCalibrationItem {
+		return structs.ServoCalibrationItem{
+			Alias:          alias,
+			PinId:          pin,
+			ActuationRange: 270,
+			MinPulse:       420,
+			MaxPulse:       2500,
+			Mapping: structs.ServoJointMapping{
+				Leg: leg, Joint: joint, ZeroOffset: zeroOffset, Direction: direction, GearRatio: 1,
+			},
+		}
+	}
+
+	return structs.ServoCalibrationMap{
+		Servos: []structs.ServoCalibrationItem{
+			item("front-left-leg", 13, consts.LegLeftFront, consts.JointUpper, 150, -1),
+			item("front-left-foot", 12, consts.LegLeftFront, consts.JointLower, 150, 1),
+			item("back-right-shoulder", 6, consts.LegRightBack, consts.JointHip, 135, -1),
+		},
+	}
+}
+
+func newSimFixture(t *testing.T, n *mq.Nats, config structs.JointsConfig) *simFixture {
+	device := new(base.FakeBus).Init().Device(drivers.DefaultPCA9685Address)
+
+	pca, err := new(drivers.PCA9685).Init(device, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servoMap := testServoMap()
+
+	fixture := &simFixture{
+		bank:   new(ServoBank).Init(n, device, pca.GetFreq(), config),
+		servos: map[string]*components.Servo{},
+	}
+
+	fixture.bank.SetCalibration(servoMap)
+
+	fixture.jointMapper, err = new(components.JointMapper).Init(servoMap, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range servoMap.Servos {
+		fixture.servos[item.Alias] = new(components.Servo).Init(pca, item.PinId, &components.ServoOptions{
+			ActuationRange: item.ActuationRange,
+			MinPulse:       item.MinPulse,
+			MaxPulse:       item.MaxPulse,
+		})
+	}
+
+	return fixture
+}
+
+// drive writes the joints to the servos through the joint mapper
+func (fixture *simFixture) drive(t *testing.T, joints *messages.Joints) {
+	for _, command := range fixture.jointMapper.Map(joints) {
+		if err := fixture.servos[command.Alias].Degrees(command.Angle); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// One tick of the pca9685 is about 0.63 degrees of a 270 degree servo
+const jointTolerance = 0.015
+
+func jointsClose(a *messages.Joints, b *messages.Joints) bool {
+	for _, legs := range [][2]hmath.Vec3{
+		{a.LeftFront, b.LeftFront}, {a.RightFront, b.RightFront}, {a.LeftBack, b.LeftBack}, {a.RightBack, b.RightBack},
+	} {
+		for i := 0; i < 3; i++ {
+			if math.Abs(legs[0][i]-legs[1][i]) > jointTolerance {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func TestServoBankSlewRate(t *testing.T) {
+	fixture := newSimFixture(t, nil, structs.JointsConfig{SimSlewRate: 100})
+
+	first := &messages.Joints{LeftFront: hmath.Vec3{0, 0.5, -1}}
+	fixture.drive(t, first)
+
+	// Servos that have never been driven jump straight to their first command
+	if joints := fixture.bank.JointState(); !jointsClose(joints, first) {
+		t.Fatalf("first command reported as %+v, expected %+v", joints, first)
+	}
+
+	// The upper joint moves 20 degrees, 0.1 seconds at 100 deg/s covers half of it
+	second := &messages.Joints{LeftFront: hmath.Vec3{0, 0.5 + 20*math.Pi/180, -1}}
+	fixture.drive(t, second)
+
+	fixture.bank.Step(0.1)
+
+	halfway := &messages.Joints{LeftFront: hmath.Vec3{0, 0.5 + 10*math.Pi/180, -1}}
+	if joints := fixture.bank.JointState(); !jointsClose(joints, halfway) {
+		t.Fatalf("joints after half the move %+v, expected %+v", joints, halfway)
+	}
+
+	fixture.bank.Step(0.2)
+
+	if joints := fixture.bank.JointState(); !jointsClose(joints, second) {
+		t.Fatalf("joints after the move %+v, expected %+v", joints, second)
+	}
+}
+
+func TestServoBankPublishesJointState(t *testing.T) {
+	server := new(mq.Server).Init(structs.NatsConfig{Host: "127.0.0.1:-1"})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(server.Shutdown)
+
+	node := new(mq.Nats).Init(structs.NatsConfig{Host: server.Addr(), Name: consts.NodeNameJoints})
+	client := new(mq.Nats).Init(structs.NatsConfig{Host: server.Addr(), Name: "test"})
+
+	for _, connection := range []*mq.Nats{node, client} {
+		if err := connection.Connect(); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(connection.Conn.Close)
+	}
+
+	published := make(chan *nats.Msg, 100)
+
+	if _, err := client.Conn.ChanSubscribe(consts.MQSimJointsChannel, published); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	fixture := newSimFixture(t, node, structs.JointsConfig{SimSlewRate: 1000, SimPublishRate: 100})
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		fixture.bank.Run(stop)
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+
+	fixture.drive(t, &messages.Joints{LeftFront: hmath.Vec3{0, 0.2, -0.4}})
+
+	// The frame is driven while the bank runs, the servos slew to it from the first frame
+	frame := &messages.Joints{
+		LeftFront: hmath.Vec3{0, 0.77, -1.3},
+		RightBack: hmath.Vec3{0.1, 0, 0},
+	}
+
+	fixture.drive(t, frame)
+
+	timeout := time.After(2 * time.Second)
+
+	var last *messages.Joints
+
+	for {
+		select {
+		case msg := <-published:
+			message := new(messages.Message)
+
+			if err := message.Unpack(msg.Data); err != nil {
+				t.Fatal(err)
+			}
+
+			if message.Type != messages.JointsMessage {
+				t.Fatalf("expected a joints message on %s, got type %d", consts.MQSimJointsChannel, message.Type)
+			}
+
+			last = new(messages.Joints)
+
+			if err := last.Unpack(message.Data); err != nil {
+				t.Fatal(err)
+			}
+
+			if jointsClose(last, frame) {
+				return
+			}
+
+		case <-timeout:
+			t.Fatalf("published joint state %+v never reached the frame %+v", last, frame)
+		}
+	}
+}
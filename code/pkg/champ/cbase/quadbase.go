@@ -30,6 +30,8 @@ func (quadBase *QuadBase) Init(gaitConfig cstructs.GaitConfig) *QuadBase {
 	quadBase.Legs = append(quadBase.Legs, quadBase.LeftBack)
 	quadBase.Legs = append(quadBase.Legs, quadBase.RightBack)
 
+	quadBase.SetGaitConfig(gaitConfig)
+
 	return quadBase
 }
 
@@ -96,6 +98,18 @@ func (quadBase *QuadBase) SetGaitConfig(gaitConfig cstructs.GaitConfig) {
 	}
 }
 
+// SetGeometry applies the joint origins in the geometry to every leg
+func (quadBase *QuadBase) SetGeometry(geometry cstructs.QuadGeometry) {
+	for i := 0; i < 4; i++ {
+		leg := geometry.Legs[i]
+
+		quadBase.Legs[i].HipJoint.SetOrigin(leg.Hip.Translation, leg.Hip.Rotation)
+		quadBase.Legs[i].UpperLegJoint.SetOrigin(leg.UpperLeg.Translation, leg.UpperLeg.Rotation)
+		quadBase.Legs[i].LowerLegJoint.SetOrigin(leg.LowerLeg.Translation, leg.LowerLeg.Rotation)
+		quadBase.Legs[i].FootJoint.SetOrigin(leg.Foot.Translation, leg.Foot.Rotation)
+	}
+}
+
 func (quadBase *QuadBase) GaitConfig() cstructs.GaitConfig {
 	return quadBase.gaitConfig
 }
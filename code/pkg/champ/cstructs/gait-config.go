@@ -11,13 +11,13 @@ type GaitConfig struct {
 	ComXTranslation    float32
 	SwingHeight        float32
 	StanceDepth        float32
-	StanceDuration     float32
 	NominalHeight      float32
+	Gait               string
 }
 
 func (gaitConfig *GaitConfig) Init(kneeOrientation string, pantoLeg bool, odomScalar float32,
 	maxLinearVelocity hmath.Vec2, comXTrans float32, swingHeight float32, stanceDepth float32,
-	stanceDuration float32, nominalHeight float32) *GaitConfig {
+	nominalHeight float32) *GaitConfig {
 
 	*gaitConfig = GaitConfig{
 		KneeOrientation:   kneeOrientation,
@@ -27,9 +27,27 @@ func (gaitConfig *GaitConfig) Init(kneeOrientation string, pantoLeg bool, odomSc
 		ComXTranslation:   comXTrans,
 		SwingHeight:       swingHeight,
 		StanceDepth:       stanceDepth,
-		StanceDuration:    stanceDuration,
 		NominalHeight:     nominalHeight,
 	}
 
 	return gaitConfig
 }
+
+// Defaults returns the gait configuration used by the micro-hal bot
+func (gaitConfig *GaitConfig) Defaults() *GaitConfig {
+
+	*gaitConfig = GaitConfig{
+		KneeOrientation:    ">>",
+		PantographLeg:      false,
+		OdomScalar:         1.0,
+		MaxLinearVelocity:  hmath.Vec2{0.5, 0.25},
+		MaxAngularVelocity: 1.0,
+		ComXTranslation:    0.0,
+		SwingHeight:        0.04,
+		StanceDepth:        0.0,
+		NominalHeight:      0.20,
+		Gait:               "trot",
+	}
+
+	return gaitConfig
+}
@@ -0,0 +1,21 @@
+package cstructs
+
+// Gait describes the timing of a gait. Phase offsets are fractions of the stride in
+// left front, right front, left back, right back order, the duty factor is the fraction
+// of the stride each leg spends in stance and the swing period is in seconds
+type Gait struct {
+	Name         string
+	PhaseOffsets [4]float32
+	DutyFactor   float32
+	SwingPeriod  float32
+}
+
+// StancePeriod returns the time in seconds each leg spends in stance
+func (gait Gait) StancePeriod() float32 {
+	return gait.SwingPeriod * gait.DutyFactor / (1 - gait.DutyFactor)
+}
+
+// StridePeriod returns the time in seconds of a full stride
+func (gait Gait) StridePeriod() float32 {
+	return gait.SwingPeriod + gait.StancePeriod()
+}
@@ -0,0 +1,53 @@
+package cstructs
+
+import "github.com/r4stl1n/micro-hal/code/pkg/hmath"
+
+// JointOrigin is the translation and rotation of a joint relative to its parent
+type JointOrigin struct {
+	Translation hmath.Vec3
+	Rotation    hmath.Vec3
+}
+
+// LegGeometry stores the joint origins that make up a single leg
+type LegGeometry struct {
+	Hip      JointOrigin
+	UpperLeg JointOrigin
+	LowerLeg JointOrigin
+	Foot     JointOrigin
+}
+
+// QuadGeometry stores the geometry of all four legs in left front, right front,
+// left back, right back order
+type QuadGeometry struct {
+	Legs [4]LegGeometry
+}
+
+// Defaults returns the micro-hal geometry as described in sim/urdf/micro-hal.urdf
+func (quadGeometry *QuadGeometry) Defaults() *QuadGeometry {
+
+	*quadGeometry = QuadGeometry{}
+
+	hipOffsets := [4]hmath.Vec3{
+		{0.093, 0.0395, 0.0},
+		{0.093, -0.0395, 0.0},
+		{-0.093, 0.0395, 0.0},
+		{-0.093, -0.0395, 0.0},
+	}
+
+	for i := 0; i < 4; i++ {
+		side := float32(1.0)
+
+		if i%2 == 1 {
+			side = -1.0
+		}
+
+		quadGeometry.Legs[i] = LegGeometry{
+			Hip:      JointOrigin{Translation: hipOffsets[i]},
+			UpperLeg: JointOrigin{Translation: hmath.Vec3{0.0, 0.055 * side, 0.0}},
+			LowerLeg: JointOrigin{Translation: hmath.Vec3{0.014, 0.0, -0.109}},
+			Foot:     JointOrigin{Translation: hmath.Vec3{0.0, 0.0, -0.13}},
+		}
+	}
+
+	return quadGeometry
+}
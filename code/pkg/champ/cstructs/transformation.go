@@ -22,15 +22,15 @@ func (transformation Transformation) Z() float32 {
 	return transformation.Point.Z()
 }
 
-func (transformation Transformation) SetX(x float32) {
+func (transformation *Transformation) SetX(x float32) {
 	transformation.Point.SetX(x)
 }
 
-func (transformation Transformation) SetY(y float32) {
+func (transformation *Transformation) SetY(y float32) {
 	transformation.Point.SetY(y)
 }
 
-func (transformation Transformation) SetZ(z float32) {
+func (transformation *Transformation) SetZ(z float32) {
 	transformation.Point.SetZ(z)
 }
 
@@ -0,0 +1,22 @@
+package cstructs
+
+import "testing"
+
+func TestTransformationSetters(t *testing.T) {
+	var transformation Transformation
+
+	transformation.SetX(0.1)
+	transformation.SetY(-0.2)
+	transformation.SetZ(0.3)
+
+	if transformation.X() != 0.1 || transformation.Y() != -0.2 || transformation.Z() != 0.3 {
+		t.Fatalf("setters left the point at %v", transformation.Point)
+	}
+
+	// Translating keeps the values set on the transformation
+	if translated := transformation.Translate(0.1, 0.2, -0.3); translated.X() != 0.2 || translated.Y() != 0 ||
+		translated.Z() != 0 {
+
+		t.Fatalf("translated point is at %v", translated.Point)
+	}
+}
@@ -0,0 +1,74 @@
+package champ
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+)
+
+const (
+	GaitWalk  = "walk"
+	GaitTrot  = "trot"
+	GaitPace  = "pace"
+	GaitBound = "bound"
+	GaitPronk = "pronk"
+
+	DefaultGait = GaitTrot
+)
+
+var gaitLibrary = map[string]cstructs.Gait{
+	GaitWalk: {
+		Name:         GaitWalk,
+		PhaseOffsets: [4]float32{0.0, 0.5, 0.75, 0.25},
+		DutyFactor:   0.75,
+		SwingPeriod:  0.15,
+	},
+	GaitTrot: {
+		Name:         GaitTrot,
+		PhaseOffsets: [4]float32{0.0, 0.5, 0.5, 0.0},
+		DutyFactor:   0.5,
+		SwingPeriod:  0.25,
+	},
+	GaitPace: {
+		Name:         GaitPace,
+		PhaseOffsets: [4]float32{0.0, 0.5, 0.0, 0.5},
+		DutyFactor:   0.5,
+		SwingPeriod:  0.25,
+	},
+	GaitBound: {
+		Name:         GaitBound,
+		PhaseOffsets: [4]float32{0.0, 0.0, 0.5, 0.5},
+		DutyFactor:   0.5,
+		SwingPeriod:  0.2,
+	},
+	GaitPronk: {
+		Name:         GaitPronk,
+		PhaseOffsets: [4]float32{0.0, 0.0, 0.0, 0.0},
+		DutyFactor:   0.5,
+		SwingPeriod:  0.2,
+	},
+}
+
+// GaitByName returns the gait with the given name from the gait library
+func GaitByName(name string) (cstructs.Gait, error) {
+	gait, ok := gaitLibrary[name]
+	if !ok {
+		return cstructs.Gait{}, fmt.Errorf("unknown gait: %s", name)
+	}
+
+	return gait, nil
+}
+
+// GaitNames returns the names of every gait in the gait library
+func GaitNames() []string {
+	names := make([]string, 0, len(gaitLibrary))
+
+	for name := range gaitLibrary {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -72,26 +72,57 @@ func (kinematics *Kinematics) inverseF(quadLeg *cbase.QuadLeg, footPosition cstr
 
 	targetToFoot := math.Sqrt(math.Pow(x, 2) + math.Pow(z, 2))
 
+	// The target is out of reach, report it so Inverse keeps the previous joints
 	if targetToFoot >= (math.Abs(l1) + math.Abs(l2)) {
-		return hipJoint, lowerLegJoint, upperLegJoint
+		return hipJoint, math.NaN(), math.NaN()
 	}
 
 	lowerLegJoint = float32(quadLeg.KneeDirection()) * math.Acos((math.Pow(z, 2)+math.Pow(x, 2)-math.Pow(l1, 2)-math.Pow(l2, 2))/(2*l1*l2))
-	upperLegJoint = math.Atan(x/z) - math.Atan((l2*math.Sin(lowerLegJoint))/(l1+(l2*math.Cos(lowerLegJoint))))
 	lowerLegJoint = lowerLegJoint + (ikBeta - ikAlpha)
-	upperLegJoint = upperLegJoint + ikAlpha
 
-	if quadLeg.KneeDirection() < 0 {
-		if upperLegJoint < 0 {
-			upperLegJoint = upperLegJoint + math.Pi
-		}
-	} else {
-		if upperLegJoint > 0 {
-			upperLegJoint = upperLegJoint + math.Pi
-		}
+	// Rotating about y turns the (z, x) plane so the upper leg angle is the difference
+	// between the heading of the target and the heading of the lower leg and foot chain.
+	// Using atan2 keeps the result on the correct side for both knee orientations
+	chainX := quadLeg.LowerLegJoint.X() + (math.Cos(lowerLegJoint) * quadLeg.FootJoint.X()) + (math.Sin(lowerLegJoint) * quadLeg.FootJoint.Z())
+	chainZ := quadLeg.LowerLegJoint.Z() - (math.Sin(lowerLegJoint) * quadLeg.FootJoint.X()) + (math.Cos(lowerLegJoint) * quadLeg.FootJoint.Z())
+
+	upperLegJoint = math.Atan2(x, z) - math.Atan2(chainX, chainZ)
+
+	if upperLegJoint > math.Pi {
+		upperLegJoint = upperLegJoint - (2 * math.Pi)
+	} else if upperLegJoint < -math.Pi {
+		upperLegJoint = upperLegJoint + (2 * math.Pi)
+	}
+
+	return hipJoint, upperLegJoint, lowerLegJoint
+}
+
+// Forward computes the foot position of every leg relative to its hip from the given
+// joint angles. It is the counterpart of Inverse and does not depend on the joint
+// angles stored in the quad base
+func (kinematics *Kinematics) Forward(jointPositions [12]float32) [4]cstructs.Transformation {
+
+	footPositions := [4]cstructs.Transformation{}
+
+	for i := 0; i < 4; i++ {
+		footPositions[i] = kinematics.forwardF(kinematics.quadBase.Legs[i], jointPositions[(i*3)], jointPositions[(i*3)+1], jointPositions[(i*3)+2])
 	}
 
-	return hipJoint, lowerLegJoint, upperLegJoint
+	return footPositions
+}
+
+func (kinematics *Kinematics) forwardF(quadLeg *cbase.QuadLeg, hipJoint float32, upperLegJoint float32, lowerLegJoint float32) cstructs.Transformation {
+
+	var footPosition cstructs.Transformation
+
+	footPosition = footPosition.Translate(quadLeg.FootJoint.X(), quadLeg.FootJoint.Y(), quadLeg.FootJoint.Z())
+	footPosition = footPosition.RotateY(lowerLegJoint)
+	footPosition = footPosition.Translate(quadLeg.LowerLegJoint.X(), quadLeg.LowerLegJoint.Y(), quadLeg.LowerLegJoint.Z())
+	footPosition = footPosition.RotateY(upperLegJoint)
+	footPosition = footPosition.Translate(quadLeg.UpperLegJoint.X(), quadLeg.UpperLegJoint.Y(), quadLeg.UpperLegJoint.Z())
+	footPosition = footPosition.RotateX(hipJoint)
+
+	return footPosition
 }
 
 func KinematicsTransformToHip(footPosition cstructs.Transformation, quadLeg *cbase.QuadLeg) cstructs.Transformation {
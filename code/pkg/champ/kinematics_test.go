@@ -0,0 +1,187 @@
+package champ
+
+import (
+	"math/rand"
+	"testing"
+
+	math "github.com/chewxy/math32"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cbase"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+)
+
+const kinematicsSamples = 2000
+
+func newKinematics(kneeOrientation string) (*Kinematics, *cbase.QuadBase) {
+	gaitConfig := *new(cstructs.GaitConfig).Defaults()
+	gaitConfig.KneeOrientation = kneeOrientation
+
+	quadBase := new(cbase.QuadBase).Init(gaitConfig)
+	quadBase.SetGeometry(*new(cstructs.QuadGeometry).Defaults())
+
+	return new(Kinematics).Init(quadBase), quadBase
+}
+
+// randomJoints returns joint angles that put every foot below its hip with the knee bent
+// far enough from the singular straight leg
+func randomJoints(random *rand.Rand, quadBase *cbase.QuadBase) [12]float32 {
+	between := func(low float32, high float32) float32 {
+		return low + (random.Float32() * (high - low))
+	}
+
+	joints := [12]float32{}
+
+	for i := 0; i < 4; i++ {
+		knee := float32(quadBase.Legs[i].KneeDirection())
+
+		joints[(i * 3)] = between(-0.4, 0.4)
+		joints[(i*3)+1] = between(-0.6, 0.6)
+		joints[(i*3)+2] = knee * between(0.3, 1.8)
+	}
+
+	return joints
+}
+
+func footsClose(a [4]cstructs.Transformation, b [4]cstructs.Transformation, tolerance float32) bool {
+	for i := 0; i < 4; i++ {
+		if math.Abs(a[i].X()-b[i].X()) > tolerance || math.Abs(a[i].Y()-b[i].Y()) > tolerance ||
+			math.Abs(a[i].Z()-b[i].Z()) > tolerance {
+			return false
+		}
+	}
+
+	return true
+}
+
+func reachable(footPositions [4]cstructs.Transformation) bool {
+	for i := 0; i < 4; i++ {
+		if footPositions[i].Z() > -0.05 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestKinematicsRoundTrip(t *testing.T) {
+	for _, kneeOrientation := range []string{">>", "><", "<>", "<<"} {
+		t.Run(kneeOrientation, func(t *testing.T) {
+			kinematics, quadBase := newKinematics(kneeOrientation)
+			random := rand.New(rand.NewSource(1))
+
+			tested := 0
+
+			for tested < kinematicsSamples {
+				target := kinematics.Forward(randomJoints(random, quadBase))
+				if !reachable(target) {
+					continue
+				}
+
+				tested = tested + 1
+
+				previous := [12]float32{}
+				previous[0] = 42
+
+				joints := kinematics.Inverse(previous, target)
+				if joints == previous {
+					t.Fatalf("reachable target %+v was refused", target)
+				}
+
+				if reached := kinematics.Forward(joints); !footsClose(reached, target, 1e-4) {
+					t.Fatalf("joints %v reach %+v instead of %+v", joints, reached, target)
+				}
+
+				for i := 0; i < 4; i++ {
+					leg := quadBase.Legs[i]
+
+					// The knee angle without the lower leg offset bends in the configured direction
+					ikAlpha := math.Acos(leg.LowerLegJoint.X()/-math.Sqrt(math.Pow(leg.LowerLegJoint.X(), 2)+
+						math.Pow(leg.LowerLegJoint.Z(), 2))) - (math.Pi / 2)
+					ikBeta := math.Acos(leg.FootJoint.X()/-math.Sqrt(math.Pow(leg.FootJoint.X(), 2)+
+						math.Pow(leg.FootJoint.Z(), 2))) - (math.Pi / 2)
+
+					bend := joints[(i*3)+2] - (ikBeta - ikAlpha)
+					if bend*float32(leg.KneeDirection()) <= 0 {
+						t.Fatalf("leg %d bends %f against knee direction %d", i, bend, leg.KneeDirection())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKinematicsKneeOrientationsReachTheSameTarget(t *testing.T) {
+	forward, _ := newKinematics(">>")
+	backward, _ := newKinematics("<<")
+
+	random := rand.New(rand.NewSource(2))
+
+	for i := 0; i < kinematicsSamples; i++ {
+		target := forward.Forward(randomJoints(random, forward.quadBase))
+		if !reachable(target) {
+			continue
+		}
+
+		forwardJoints := forward.Inverse([12]float32{}, target)
+		backwardJoints := backward.Inverse([12]float32{}, target)
+
+		if forwardJoints == backwardJoints {
+			t.Fatalf("both knee orientations solved %+v with joints %v", target, forwardJoints)
+		}
+
+		if !footsClose(backward.Forward(backwardJoints), target, 1e-4) {
+			t.Fatalf("reversed knees do not reach %+v", target)
+		}
+	}
+}
+
+func TestKinematicsKeepsPreviousJointsForUnreachableTargets(t *testing.T) {
+	kinematics, quadBase := newKinematics(">>")
+	random := rand.New(rand.NewSource(3))
+
+	previous := randomJoints(random, quadBase)
+	nominal := kinematics.Forward(previous)
+
+	for _, target := range []cstructs.Transformation{
+		new(cstructs.Transformation).Translate(0, 0, -0.5),
+		new(cstructs.Transformation).Translate(0.3, 0, -0.1),
+		new(cstructs.Transformation).Translate(math.NaN(), 0, -0.15),
+		new(cstructs.Transformation).Translate(0, 0, math.Inf(-1)),
+	} {
+		// One leg out of reach is enough to keep every joint
+		for leg := 0; leg < 4; leg++ {
+			footPositions := nominal
+			footPositions[leg] = target
+
+			if joints := kinematics.Inverse(previous, footPositions); joints != previous {
+				t.Fatalf("leg %d with target %+v changed the joints to %v", leg, target, joints)
+			}
+		}
+	}
+}
+
+// TestInverseReturnsJointsInChainOrder places the feet with known joint angles and checks
+// that the joints returned by Inverse put the feet back in the same place. The joints of
+// every leg must come back as hip, upper leg, lower leg
+func TestInverseReturnsJointsInChainOrder(t *testing.T) {
+	kinematics, quadBase := newKinematics(">>")
+
+	for _, hipJoint := range []float32{-0.2, 0, 0.2} {
+		for _, upperLegJoint := range []float32{0.3, 0.6} {
+			for _, bend := range []float32{0.4, 0.8, 1.2, 1.6} {
+				joints := [12]float32{}
+
+				for i, leg := range quadBase.Legs {
+					joints[(i * 3)] = hipJoint
+					joints[(i*3)+1] = upperLegJoint
+					joints[(i*3)+2] = float32(leg.KneeDirection()) * bend
+				}
+
+				targets := kinematics.Forward(joints)
+
+				if reached := kinematics.Forward(kinematics.Inverse([12]float32{}, targets)); !footsClose(reached, targets, 1e-4) {
+					t.Fatalf("joints %v reach %+v instead of %+v", joints, reached, targets)
+				}
+			}
+		}
+	}
+}
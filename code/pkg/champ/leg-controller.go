@@ -40,6 +40,23 @@ func (legController *LegController) Init(quadBase *cbase.QuadBase, currentTime t
 	return legController
 }
 
+// SetGait switches the phase generator to the named gait from the gait library
+func (legController *LegController) SetGait(name string) error {
+	gait, err := GaitByName(name)
+	if err != nil {
+		return err
+	}
+
+	legController.phaseGenerator.SetGait(gait)
+
+	return nil
+}
+
+// Gait returns the gait the leg controller is running or switching to
+func (legController *LegController) Gait() cstructs.Gait {
+	return legController.phaseGenerator.Gait()
+}
+
 func (legController *LegController) capVelocities(velocity float32, minVelocity float32, maxVelocity float32) float32 {
 
 	if velocity < minVelocity {
@@ -101,9 +118,11 @@ func (legController *LegController) VelocityCommand(footPositions [4]cstructs.Tr
 	tangentialVelocity := velocities.Angular.Z() * legController.quadBase.LeftFront.CenterToNominal()
 	velocity := math.Sqrt(math.Pow(velocities.Linear.X(), 2) + math.Pow(velocities.Linear.Y()+tangentialVelocity, 2))
 
-	stepX := legController.RaibertHeuristic(legController.quadBase.GaitConfig().StanceDuration, velocities.Linear.X())
-	stepY := legController.RaibertHeuristic(legController.quadBase.GaitConfig().StanceDuration, velocities.Linear.Y())
-	stepTheta := legController.RaibertHeuristic(legController.quadBase.GaitConfig().StanceDuration, tangentialVelocity)
+	stanceDuration := legController.phaseGenerator.StanceDuration(currentTime)
+
+	stepX := legController.RaibertHeuristic(stanceDuration, velocities.Linear.X())
+	stepY := legController.RaibertHeuristic(stanceDuration, velocities.Linear.Y())
+	stepTheta := legController.RaibertHeuristic(stanceDuration, tangentialVelocity)
 
 	theta := math.Sin((stepTheta/2)/legController.quadBase.LeftFront.CenterToNominal()) * 2
 
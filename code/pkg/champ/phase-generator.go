@@ -1,7 +1,9 @@
 package champ
 
 import (
+	math "github.com/chewxy/math32"
 	"github.com/r4stl1n/micro-hal/code/pkg/champ/cbase"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
 	"time"
 )
 
@@ -14,10 +16,22 @@ type PhaseGenerator struct {
 	hasStarted        bool
 	stancePhaseSignal [4]float32
 	swingPhaseSignal  [4]float32
+
+	gait          cstructs.Gait
+	previousGait  cstructs.Gait
+	pendingGait   *cstructs.Gait
+	blending      bool
+	blendStart    time.Time
+	blendDuration float32
 }
 
 func (phaseGenerator *PhaseGenerator) Init(quadBase *cbase.QuadBase, currentTime time.Time) *PhaseGenerator {
 
+	gait, err := GaitByName(quadBase.GaitConfig().Gait)
+	if err != nil {
+		gait, _ = GaitByName(DefaultGait)
+	}
+
 	*phaseGenerator = PhaseGenerator{
 		base:              quadBase,
 		lastTouchDown:     currentTime,
@@ -25,28 +39,86 @@ func (phaseGenerator *PhaseGenerator) Init(quadBase *cbase.QuadBase, currentTime
 		hasStarted:        false,
 		stancePhaseSignal: [4]float32{0.0, 0.0, 0.0, 0.0},
 		swingPhaseSignal:  [4]float32{0.0, 0.0, 0.0, 0.0},
+		gait:              gait,
 	}
 
 	return phaseGenerator
 }
 
+// SetGait queues the gait to be used from the next stride boundary. The switch is
+// blended over one stride so the legs do not jump to their new phase
+func (phaseGenerator *PhaseGenerator) SetGait(gait cstructs.Gait) {
+	phaseGenerator.pendingGait = &gait
+}
+
+// Gait returns the gait the phase generator is running or blending towards
+func (phaseGenerator *PhaseGenerator) Gait() cstructs.Gait {
+	if phaseGenerator.pendingGait != nil {
+		return *phaseGenerator.pendingGait
+	}
+
+	return phaseGenerator.gait
+}
+
+// StanceDuration returns the stance period in seconds of the gait currently in effect
+func (phaseGenerator *PhaseGenerator) StanceDuration(currentTime time.Time) float32 {
+	return phaseGenerator.effectiveGait(currentTime).StancePeriod()
+}
+
+// blendProgress returns how far the current blend has progressed from 0 to 1
+func (phaseGenerator *PhaseGenerator) blendProgress(currentTime time.Time) float32 {
+	return float32(currentTime.Sub(phaseGenerator.blendStart).Seconds()) / phaseGenerator.blendDuration
+}
+
+func (phaseGenerator *PhaseGenerator) effectiveGait(currentTime time.Time) cstructs.Gait {
+	if !phaseGenerator.blending {
+		return phaseGenerator.gait
+	}
+
+	t := phaseGenerator.blendProgress(currentTime)
+
+	if t >= 1.0 {
+		return phaseGenerator.gait
+	}
+
+	return blendGaits(phaseGenerator.previousGait, phaseGenerator.gait, t)
+}
+
+func (phaseGenerator *PhaseGenerator) applyPendingGait(currentTime time.Time, blend bool) {
+	if phaseGenerator.pendingGait == nil {
+		return
+	}
+
+	previousGait := phaseGenerator.effectiveGait(currentTime)
+
+	phaseGenerator.gait = *phaseGenerator.pendingGait
+	phaseGenerator.pendingGait = nil
+	phaseGenerator.blending = blend
+
+	if blend {
+		phaseGenerator.previousGait = previousGait
+		phaseGenerator.blendStart = currentTime
+		phaseGenerator.blendDuration = math.Max(previousGait.StridePeriod(), phaseGenerator.gait.StridePeriod())
+	}
+}
+
 func (phaseGenerator *PhaseGenerator) Run(targetVelocity float32, stepLength float32, currentTime time.Time) {
 
 	secondsToMicro := float32(1000000)
 
-	elapsedTimeRef := float32(0.0)
-	swingPhasePeriod := 0.25 * secondsToMicro
-	legClocks := [4]float32{0.0, 0.0, 0.0, 0.0}
-	stancePhasePeriod := phaseGenerator.base.GaitConfig().StanceDuration * secondsToMicro
-	stridePeriod := stancePhasePeriod + swingPhasePeriod
+	if phaseGenerator.blending && phaseGenerator.blendProgress(currentTime) >= 1.0 {
+		phaseGenerator.blending = false
+	}
 
 	if targetVelocity == 0.0 {
-		elapsedTimeRef = 0
 		phaseGenerator.lastTouchDown = time.Time{}
 		phaseGenerator.hasSwung = false
 
+		// Standing still there is nothing to blend so switch straight away
+		phaseGenerator.applyPendingGait(currentTime, false)
+		phaseGenerator.blending = false
+
 		for i := 0; i < 4; i++ {
-			legClocks[i] = 0.0
 			phaseGenerator.stancePhaseSignal[i] = 0.0
 			phaseGenerator.swingPhaseSignal[i] = 0.0
 		}
@@ -58,33 +130,32 @@ func (phaseGenerator *PhaseGenerator) Run(targetVelocity float32, stepLength flo
 		phaseGenerator.lastTouchDown = currentTime
 	}
 
+	gait := phaseGenerator.effectiveGait(currentTime)
+	stridePeriod := gait.StridePeriod() * secondsToMicro
+
 	if float32(currentTime.Sub(phaseGenerator.lastTouchDown).Microseconds()) >= stridePeriod {
 		phaseGenerator.lastTouchDown = currentTime
-	}
 
-	if elapsedTimeRef >= stridePeriod {
-		elapsedTimeRef = stridePeriod
-	} else {
-		elapsedTimeRef = float32(currentTime.Sub(phaseGenerator.lastTouchDown).Microseconds())
+		phaseGenerator.applyPendingGait(currentTime, true)
+
+		gait = phaseGenerator.effectiveGait(currentTime)
+		stridePeriod = gait.StridePeriod() * secondsToMicro
 	}
 
-	legClocks[0] = elapsedTimeRef - (0.0 * stridePeriod)
-	legClocks[1] = elapsedTimeRef - (0.5 * stridePeriod)
-	legClocks[2] = elapsedTimeRef - (0.5 * stridePeriod)
-	legClocks[3] = elapsedTimeRef - (0.5 * stridePeriod)
+	elapsedTimeRef := float32(currentTime.Sub(phaseGenerator.lastTouchDown).Microseconds())
 
 	for i := 0; i < 4; i++ {
+		legPhase := (elapsedTimeRef / stridePeriod) - gait.PhaseOffsets[i]
+		legPhase = legPhase - math.Floor(legPhase)
 
-		if legClocks[i] > 0 && legClocks[i] < stancePhasePeriod {
-			phaseGenerator.stancePhaseSignal[i] = legClocks[i] / stancePhasePeriod
+		if legPhase > 0 && legPhase < gait.DutyFactor {
+			phaseGenerator.stancePhaseSignal[i] = legPhase / gait.DutyFactor
 		} else {
 			phaseGenerator.stancePhaseSignal[i] = 0
 		}
 
-		if legClocks[i] > -swingPhasePeriod && legClocks[i] < 0 {
-			phaseGenerator.swingPhaseSignal[i] = (legClocks[i] + swingPhasePeriod) / swingPhasePeriod
-		} else if legClocks[i] > swingPhasePeriod && legClocks[i] < stridePeriod {
-			phaseGenerator.swingPhaseSignal[i] = (legClocks[i] - stancePhasePeriod) / swingPhasePeriod
+		if legPhase > gait.DutyFactor {
+			phaseGenerator.swingPhaseSignal[i] = (legPhase - gait.DutyFactor) / (1 - gait.DutyFactor)
 		} else {
 			phaseGenerator.swingPhaseSignal[i] = 0
 		}
@@ -99,3 +170,23 @@ func (phaseGenerator *PhaseGenerator) Run(targetVelocity float32, stepLength flo
 		phaseGenerator.hasSwung = true
 	}
 }
+
+// blendGaits interpolates between two gaits, phase offsets take the shortest way
+// around the stride
+func blendGaits(from cstructs.Gait, to cstructs.Gait, t float32) cstructs.Gait {
+	blended := cstructs.Gait{
+		Name:        to.Name,
+		DutyFactor:  from.DutyFactor + (to.DutyFactor-from.DutyFactor)*t,
+		SwingPeriod: from.SwingPeriod + (to.SwingPeriod-from.SwingPeriod)*t,
+	}
+
+	for i := 0; i < 4; i++ {
+		delta := to.PhaseOffsets[i] - from.PhaseOffsets[i]
+		delta = delta - math.Round(delta)
+
+		offset := from.PhaseOffsets[i] + delta*t
+		blended.PhaseOffsets[i] = offset - math.Floor(offset)
+	}
+
+	return blended
+}
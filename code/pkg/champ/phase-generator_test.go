@@ -0,0 +1,50 @@
+package champ
+
+import (
+	"testing"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cbase"
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+)
+
+func TestPhaseGeneratorBlendsGaitSwitch(t *testing.T) {
+	trot, _ := GaitByName(GaitTrot)
+	walk, _ := GaitByName(GaitWalk)
+
+	start := time.Unix(0, 0)
+	quadBase := new(cbase.QuadBase).Init(*new(cstructs.GaitConfig).Defaults())
+	phaseGenerator := new(PhaseGenerator).Init(quadBase, start)
+
+	phaseGenerator.Run(0.1, 0.05, start)
+	phaseGenerator.SetGait(walk)
+
+	// The switch happens at the next stride boundary and blends over the longer stride
+	boundary := start.Add(time.Duration(trot.StridePeriod()*1e6) * time.Microsecond)
+	phaseGenerator.Run(0.1, 0.05, boundary)
+
+	if !phaseGenerator.blending {
+		t.Fatal("gait switch at the stride boundary is not blended")
+	}
+
+	if stance := phaseGenerator.StanceDuration(boundary); stance != trot.StancePeriod() {
+		t.Fatalf("stance duration at the start of the blend is %f, expected %f", stance, trot.StancePeriod())
+	}
+
+	blendEnd := boundary.Add(time.Duration(walk.StridePeriod()*1e6) * time.Microsecond)
+
+	// Reading the stance duration must not end the blend, only the tick does
+	if stance := phaseGenerator.StanceDuration(blendEnd); stance != walk.StancePeriod() {
+		t.Fatalf("stance duration after the blend is %f, expected %f", stance, walk.StancePeriod())
+	}
+
+	if !phaseGenerator.blending {
+		t.Fatal("reading the stance duration ended the blend")
+	}
+
+	phaseGenerator.Run(0.1, 0.05, blendEnd)
+
+	if phaseGenerator.blending {
+		t.Fatal("blend did not end on the tick after it finished")
+	}
+}
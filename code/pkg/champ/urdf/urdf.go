@@ -0,0 +1,219 @@
+package urdf
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+)
+
+// LegJointNames stores the urdf joint names that make up a single leg
+type LegJointNames struct {
+	Hip      string `json:"hip"`
+	UpperLeg string `json:"upperLeg"`
+	LowerLeg string `json:"lowerLeg"`
+	Foot     string `json:"foot"`
+}
+
+// JointNames maps the urdf joints onto the legs in left front, right front,
+// left back, right back order
+type JointNames struct {
+	Legs [4]LegJointNames `json:"legs"`
+}
+
+// Defaults returns the joint names used by sim/urdf/micro-hal.urdf
+func (jointNames *JointNames) Defaults() *JointNames {
+
+	*jointNames = JointNames{}
+
+	prefixes := [4]string{"front_left", "front_right", "rear_left", "rear_right"}
+
+	for i, prefix := range prefixes {
+		jointNames.Legs[i] = LegJointNames{
+			Hip:      prefix + "_shoulder",
+			UpperLeg: prefix + "_leg",
+			LowerLeg: prefix + "_foot",
+			Foot:     prefix + "_toe",
+		}
+	}
+
+	return jointNames
+}
+
+// Load reads the joint names from a json file, legs and joints missing from the file
+// are left unchanged
+func (jointNames *JointNames) Load(path string) error {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	return jointNames.Unmarshal(data)
+}
+
+// Unmarshal reads the joint names from a json document, legs and joints missing from
+// the document are left unchanged
+func (jointNames *JointNames) Unmarshal(data []byte) error {
+
+	// Legs are decoded one by one as json zeroes the array elements missing from a document
+	var document struct {
+		Legs []json.RawMessage `json:"legs"`
+	}
+
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to parse joint names: %w", err)
+	}
+
+	if len(document.Legs) > len(jointNames.Legs) {
+		return fmt.Errorf("expected at most %d legs in the joint names, got %d", len(jointNames.Legs), len(document.Legs))
+	}
+
+	for i, leg := range document.Legs {
+		if err := json.Unmarshal(leg, &jointNames.Legs[i]); err != nil {
+			return fmt.Errorf("failed to parse joint names of leg %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+type robot struct {
+	Name   string  `xml:"name,attr"`
+	Joints []joint `xml:"joint"`
+}
+
+type joint struct {
+	Name   string `xml:"name,attr"`
+	Type   string `xml:"type,attr"`
+	Origin origin `xml:"origin"`
+}
+
+type origin struct {
+	Xyz string `xml:"xyz,attr"`
+	Rpy string `xml:"rpy,attr"`
+}
+
+// Load reads the urdf file at the given path and returns the geometry of the legs
+func Load(path string, jointNames JointNames) (cstructs.QuadGeometry, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return cstructs.QuadGeometry{}, err
+	}
+
+	return Parse(data, jointNames)
+}
+
+// Parse returns the geometry of the legs described by the urdf document. Every joint
+// in the name mapping must be present in the document
+func Parse(data []byte, jointNames JointNames) (cstructs.QuadGeometry, error) {
+
+	geometry := cstructs.QuadGeometry{}
+
+	var document robot
+
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return geometry, fmt.Errorf("failed to parse urdf: %w", err)
+	}
+
+	origins := map[string]cstructs.JointOrigin{}
+
+	for _, element := range document.Joints {
+		jointOrigin, err := parseOrigin(element.Origin)
+
+		if err != nil {
+			return geometry, fmt.Errorf("joint %s: %w", element.Name, err)
+		}
+
+		origins[element.Name] = jointOrigin
+	}
+
+	lookup := func(name string) (cstructs.JointOrigin, error) {
+		jointOrigin, ok := origins[name]
+
+		if !ok {
+			return cstructs.JointOrigin{}, fmt.Errorf("joint %s not found in urdf %s", name, document.Name)
+		}
+
+		return jointOrigin, nil
+	}
+
+	for i, names := range jointNames.Legs {
+		leg := &geometry.Legs[i]
+
+		targets := []struct {
+			name   string
+			origin *cstructs.JointOrigin
+		}{
+			{names.Hip, &leg.Hip},
+			{names.UpperLeg, &leg.UpperLeg},
+			{names.LowerLeg, &leg.LowerLeg},
+			{names.Foot, &leg.Foot},
+		}
+
+		for _, target := range targets {
+			jointOrigin, err := lookup(target.name)
+
+			if err != nil {
+				return geometry, err
+			}
+
+			*target.origin = jointOrigin
+		}
+	}
+
+	return geometry, nil
+}
+
+// parseOrigin converts the xyz and rpy attributes of an origin, missing attributes
+// default to zero as in the urdf specification
+func parseOrigin(element origin) (cstructs.JointOrigin, error) {
+
+	translation, err := parseVec3(element.Xyz)
+
+	if err != nil {
+		return cstructs.JointOrigin{}, fmt.Errorf("invalid xyz: %w", err)
+	}
+
+	rotation, err := parseVec3(element.Rpy)
+
+	if err != nil {
+		return cstructs.JointOrigin{}, fmt.Errorf("invalid rpy: %w", err)
+	}
+
+	return cstructs.JointOrigin{Translation: translation, Rotation: rotation}, nil
+}
+
+func parseVec3(value string) (hmath.Vec3, error) {
+
+	vec := hmath.Vec3{}
+	fields := strings.Fields(value)
+
+	if len(fields) == 0 {
+		return vec, nil
+	}
+
+	if len(fields) != 3 {
+		return vec, fmt.Errorf("expected 3 values, got %d", len(fields))
+	}
+
+	for i, field := range fields {
+		parsed, err := strconv.ParseFloat(field, 32)
+
+		if err != nil {
+			return vec, err
+		}
+
+		vec[i] = float32(parsed)
+	}
+
+	return vec, nil
+}
@@ -0,0 +1,91 @@
+package urdf
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/champ/cstructs"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+)
+
+func fixtureJointNames(t *testing.T) JointNames {
+	jointNames := *new(JointNames).Defaults()
+
+	if err := jointNames.Load(filepath.Join("testdata", "joint-names.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	return jointNames
+}
+
+func TestLoad(t *testing.T) {
+	geometry, err := Load(filepath.Join("testdata", "quad.urdf"), fixtureJointNames(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leftFront := geometry.Legs[0]
+
+	expected := cstructs.LegGeometry{
+		Hip:      cstructs.JointOrigin{Translation: hmath.Vec3{0.1, 0.04, 0}},
+		UpperLeg: cstructs.JointOrigin{Translation: hmath.Vec3{0, 0.05, 0}},
+		LowerLeg: cstructs.JointOrigin{Translation: hmath.Vec3{0.01, 0, -0.1}, Rotation: hmath.Vec3{0, 0.2, 0}},
+		Foot:     cstructs.JointOrigin{Translation: hmath.Vec3{0, 0, -0.12}},
+	}
+
+	if leftFront != expected {
+		t.Fatalf("left front leg is %+v, expected %+v", leftFront, expected)
+	}
+
+	hips := [4]hmath.Vec3{{0.1, 0.04, 0}, {0.1, -0.04, 0}, {-0.1, 0.04, 0}, {-0.1, -0.04, 0}}
+
+	for i, hip := range hips {
+		if geometry.Legs[i].Hip.Translation != hip {
+			t.Fatalf("leg %d hip is at %v, expected %v", i, geometry.Legs[i].Hip.Translation, hip)
+		}
+	}
+}
+
+func TestLoadMissingJoint(t *testing.T) {
+	_, err := Load(filepath.Join("testdata", "quad.urdf"), *new(JointNames).Defaults())
+
+	if err == nil || !strings.Contains(err.Error(), "front_left_shoulder") {
+		t.Fatalf("expected an error for the missing joint, got %v", err)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join("testdata", "missing.urdf"), fixtureJointNames(t)); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
+
+func TestParseInvalidOrigin(t *testing.T) {
+	data := []byte(`<robot name="bad"><joint name="lf_hip"><origin xyz="0 1"/></joint></robot>`)
+
+	if _, err := Parse(data, fixtureJointNames(t)); err == nil || !strings.Contains(err.Error(), "lf_hip") {
+		t.Fatalf("expected an error for the invalid origin, got %v", err)
+	}
+}
+
+func TestJointNamesUnmarshalKeepsMissingJoints(t *testing.T) {
+	jointNames := *new(JointNames).Defaults()
+
+	if err := jointNames.Unmarshal([]byte(`{"legs": [{"hip": "lf_hip"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if jointNames.Legs[0].Hip != "lf_hip" || jointNames.Legs[0].UpperLeg != "front_left_leg" ||
+		jointNames.Legs[3].Foot != "rear_right_toe" {
+		t.Fatalf("joint names are %+v", jointNames)
+	}
+
+	if err := jointNames.Unmarshal([]byte(`{"legs": `)); err == nil {
+		t.Fatal("expected an error for invalid json")
+	}
+
+	if err := jointNames.Unmarshal([]byte(`{"legs": [{}, {}, {}, {}, {}]}`)); err == nil {
+		t.Fatal("expected an error for more than 4 legs")
+	}
+}
@@ -0,0 +1,165 @@
+package components
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+var ErrSoftLimit = errors.New("angle outside the soft limits")
+
+var (
+	legNames   = []string{consts.LegLeftFront, consts.LegRightFront, consts.LegLeftBack, consts.LegRightBack}
+	jointNames = []string{consts.JointHip, consts.JointUpper, consts.JointLower}
+)
+
+// SoftLimits returns the safe servo angle range of the servo in degrees
+func SoftLimits(item structs.ServoCalibrationItem) (float32, float32) {
+	if item.MaxAngle == 0 {
+		return item.MinAngle, float32(item.ActuationRange)
+	}
+
+	return item.MinAngle, item.MaxAngle
+}
+
+// ClampSoftLimits clamps the servo angle into the soft limits of the servo. The second
+// return value is true when the angle had to be clamped
+func ClampSoftLimits(item structs.ServoCalibrationItem, angle float32) (float32, bool) {
+	minAngle, maxAngle := SoftLimits(item)
+
+	if angle < minAngle {
+		return minAngle, true
+	}
+
+	if angle > maxAngle {
+		return maxAngle, true
+	}
+
+	return angle, false
+}
+
+// CheckSoftLimits returns an error wrapping ErrSoftLimit when the servo angle is outside
+// the soft limits of the servo
+func CheckSoftLimits(item structs.ServoCalibrationItem, angle float32) error {
+	minAngle, maxAngle := SoftLimits(item)
+
+	if angle < minAngle || angle > maxAngle {
+		return fmt.Errorf("%w: %.2f for servo %s is outside %.2f to %.2f degrees", ErrSoftLimit, angle, item.Alias,
+			minAngle, maxAngle)
+	}
+
+	return nil
+}
+
+func validateSoftLimits(item structs.ServoCalibrationItem) error {
+	minAngle, maxAngle := SoftLimits(item)
+
+	if minAngle < 0 || maxAngle > float32(item.ActuationRange) || minAngle >= maxAngle {
+		return fmt.Errorf("soft limits %.2f to %.2f of servo %s do not fit the actuation range of %d degrees",
+			minAngle, maxAngle, item.Alias, item.ActuationRange)
+	}
+
+	return nil
+}
+
+// jointConstraint is a validated structs.JointConstraint with the joints resolved to
+// their index in the leg and the points ordered by angle
+type jointConstraint struct {
+	joint     int
+	dependsOn int
+	points    []structs.ConstraintPoint
+}
+
+func newJointConstraint(constraint structs.JointConstraint) (jointConstraint, error) {
+	joint := indexOf(jointNames, constraint.Joint)
+	dependsOn := indexOf(jointNames, constraint.DependsOn)
+
+	switch {
+	case constraint.Leg != "" && indexOf(legNames, constraint.Leg) < 0:
+		return jointConstraint{}, fmt.Errorf("joint constraint has unknown leg %s", constraint.Leg)
+
+	case joint < 0 || dependsOn < 0:
+		return jointConstraint{}, fmt.Errorf("joint constraint %s on %s has an unknown joint", constraint.Joint,
+			constraint.DependsOn)
+
+	case joint == dependsOn:
+		return jointConstraint{}, fmt.Errorf("joint %s can not be constrained by itself", constraint.Joint)
+
+	case len(constraint.Points) == 0:
+		return jointConstraint{}, fmt.Errorf("joint constraint %s on %s has no points", constraint.Joint,
+			constraint.DependsOn)
+	}
+
+	points := append([]structs.ConstraintPoint{}, constraint.Points...)
+
+	for _, point := range points {
+		if point.Min > point.Max {
+			return jointConstraint{}, fmt.Errorf("joint constraint %s on %s has a min above its max at %.3f",
+				constraint.Joint, constraint.DependsOn, point.Angle)
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Angle < points[j].Angle
+	})
+
+	return jointConstraint{joint: joint, dependsOn: dependsOn, points: points}, nil
+}
+
+// limits returns the allowed range of the joint at the angle of the joint it depends on
+func (constraint jointConstraint) limits(dependsOnAngle float32) (float32, float32) {
+	first := constraint.points[0]
+	last := constraint.points[len(constraint.points)-1]
+
+	if dependsOnAngle <= first.Angle {
+		return first.Min, first.Max
+	}
+
+	if dependsOnAngle >= last.Angle {
+		return last.Min, last.Max
+	}
+
+	for i := 1; i < len(constraint.points); i++ {
+		upper := constraint.points[i]
+		if dependsOnAngle > upper.Angle {
+			continue
+		}
+
+		lower := constraint.points[i-1]
+		fraction := (dependsOnAngle - lower.Angle) / (upper.Angle - lower.Angle)
+
+		return lower.Min + ((upper.Min - lower.Min) * fraction), lower.Max + ((upper.Max - lower.Max) * fraction)
+	}
+
+	return last.Min, last.Max
+}
+
+// apply clamps the constrained joint of the leg, it returns true when the joint changed
+func (constraint jointConstraint) apply(angles *hmath.Vec3) bool {
+	minAngle, maxAngle := constraint.limits(angles[constraint.dependsOn])
+
+	switch {
+	case angles[constraint.joint] < minAngle:
+		angles[constraint.joint] = minAngle
+	case angles[constraint.joint] > maxAngle:
+		angles[constraint.joint] = maxAngle
+	default:
+		return false
+	}
+
+	return true
+}
+
+func indexOf(names []string, name string) int {
+	for i, candidate := range names {
+		if candidate == name {
+			return i
+		}
+	}
+
+	return -1
+}
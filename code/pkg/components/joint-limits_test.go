@@ -0,0 +1,339 @@
+package components
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+func limitedServo(minAngle float32, maxAngle float32) structs.ServoCalibrationItem {
+	return structs.ServoCalibrationItem{Alias: "servo", ActuationRange: 180, MinAngle: minAngle, MaxAngle: maxAngle}
+}
+
+func TestSoftLimits(t *testing.T) {
+	if minAngle, maxAngle := SoftLimits(limitedServo(0, 0)); minAngle != 0 || maxAngle != 180 {
+		t.Fatalf("servo without limits allows %f to %f", minAngle, maxAngle)
+	}
+
+	if minAngle, maxAngle := SoftLimits(limitedServo(20, 0)); minAngle != 20 || maxAngle != 180 {
+		t.Fatalf("servo with a min angle only allows %f to %f", minAngle, maxAngle)
+	}
+
+	item := limitedServo(30, 150)
+
+	tests := []struct {
+		angle   float32
+		clamped float32
+		limited bool
+	}{
+		{10, 30, true},
+		{30, 30, false},
+		{90, 90, false},
+		{150, 150, false},
+		{170, 150, true},
+	}
+
+	for _, test := range tests {
+		clamped, limited := ClampSoftLimits(item, test.angle)
+
+		if clamped != test.clamped || limited != test.limited {
+			t.Errorf("%f clamped to %f %t, expected %f %t", test.angle, clamped, limited, test.clamped, test.limited)
+		}
+
+		err := CheckSoftLimits(item, test.angle)
+
+		if test.limited != errors.Is(err, ErrSoftLimit) {
+			t.Errorf("check of %f returned %v", test.angle, err)
+		}
+	}
+}
+
+func TestValidateSoftLimits(t *testing.T) {
+	for _, item := range []structs.ServoCalibrationItem{limitedServo(0, 0), limitedServo(30, 150), limitedServo(0, 180)} {
+		if err := validateSoftLimits(item); err != nil {
+			t.Errorf("limits %f to %f refused: %s", item.MinAngle, item.MaxAngle, err)
+		}
+	}
+
+	for _, item := range []structs.ServoCalibrationItem{limitedServo(-1, 0), limitedServo(0, 181), limitedServo(90, 90),
+		limitedServo(120, 60), limitedServo(180, 0)} {
+
+		if err := validateSoftLimits(item); err == nil {
+			t.Errorf("limits %f to %f accepted", item.MinAngle, item.MaxAngle)
+		}
+	}
+}
+
+func TestJointConstraintValidation(t *testing.T) {
+	points := []structs.ConstraintPoint{{Angle: 0, Min: -1, Max: 1}}
+
+	for _, constraint := range []structs.JointConstraint{
+		{Leg: "middle", Joint: consts.JointLower, DependsOn: consts.JointUpper, Points: points},
+		{Joint: "knee", DependsOn: consts.JointUpper, Points: points},
+		{Joint: consts.JointLower, DependsOn: "knee", Points: points},
+		{Joint: consts.JointLower, DependsOn: consts.JointLower, Points: points},
+		{Joint: consts.JointLower, DependsOn: consts.JointUpper},
+		{Joint: consts.JointLower, DependsOn: consts.JointUpper, Points: []structs.ConstraintPoint{{Min: 1, Max: -1}}},
+	} {
+		if _, err := newJointConstraint(constraint); err == nil {
+			t.Errorf("constraint %+v accepted", constraint)
+		}
+	}
+}
+
+func TestJointConstraintLimits(t *testing.T) {
+	// Points out of order are sorted by angle
+	constraint, err := newJointConstraint(structs.JointConstraint{
+		Joint:     consts.JointLower,
+		DependsOn: consts.JointUpper,
+		Points: []structs.ConstraintPoint{
+			{Angle: 1, Min: 0, Max: 1},
+			{Angle: -1, Min: -0.5, Max: 0.5},
+			{Angle: 2, Min: 0.5, Max: 1},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if constraint.joint != 2 || constraint.dependsOn != 1 {
+		t.Fatalf("constraint resolved to joint %d depending on %d", constraint.joint, constraint.dependsOn)
+	}
+
+	tests := []struct {
+		angle    float32
+		min, max float32
+	}{
+		{-3, -0.5, 0.5},
+		{-1, -0.5, 0.5},
+		{0, -0.25, 0.75},
+		{1, 0, 1},
+		{1.5, 0.25, 1},
+		{2, 0.5, 1},
+		{3, 0.5, 1},
+	}
+
+	for _, test := range tests {
+		minAngle, maxAngle := constraint.limits(test.angle)
+
+		if !closeTo(minAngle, test.min, 1e-6) || !closeTo(maxAngle, test.max, 1e-6) {
+			t.Errorf("limits at %f are %f to %f, expected %f to %f", test.angle, minAngle, maxAngle, test.min, test.max)
+		}
+	}
+
+	angles := hmath.Vec3{0.3, 0, 1}
+
+	if !constraint.apply(&angles) || angles != (hmath.Vec3{0.3, 0, 0.75}) {
+		t.Fatalf("constraint applied as %v", angles)
+	}
+
+	if constraint.apply(&angles) {
+		t.Fatal("constraint changed a joint that is within its limits")
+	}
+}
+
+// legServoMap maps the upper and lower joint of every leg with the servo at 90 degrees for
+// a zero joint angle and soft limits of 60 degrees either way
+func legServoMap(constraints ...structs.JointConstraint) structs.ServoCalibrationMap {
+	servoMap := structs.ServoCalibrationMap{Constraints: constraints}
+
+	for i, leg := range legNames {
+		for j, joint := range []string{consts.JointUpper, consts.JointLower} {
+			servoMap.Servos = append(servoMap.Servos, structs.ServoCalibrationItem{
+				Alias:          leg + "-" + joint,
+				PinId:          (i * 2) + j,
+				ActuationRange: 180,
+				MinAngle:       30,
+				MaxAngle:       150,
+				Mapping:        structs.ServoJointMapping{Leg: leg, Joint: joint, ZeroOffset: 90, Direction: 1},
+			})
+		}
+	}
+
+	return servoMap
+}
+
+func commandsByAlias(commands []ServoCommand) map[string]ServoCommand {
+	byAlias := map[string]ServoCommand{}
+
+	for _, command := range commands {
+		byAlias[command.Alias] = command
+	}
+
+	return byAlias
+}
+
+func TestJointMapperSoftLimits(t *testing.T) {
+	joints := &messages.Joints{LeftFront: hmath.Vec3{0, 1.5, -0.5}}
+
+	enforced, err := new(JointMapper).Init(legServoMap(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := commandsByAlias(enforced.Map(joints))
+
+	if upper := commands["left-front-upper"]; upper.Angle != 150 || !upper.Limited || upper.Clamped || upper.JointAngle != 1.5 {
+		t.Fatalf("upper joint past the soft limit mapped to %+v", upper)
+	}
+
+	if lower := commands["left-front-lower"]; lower.Limited || !closeTo(lower.Angle, 90-28.6479, 1e-3) {
+		t.Fatalf("lower joint within the soft limits mapped to %+v", lower)
+	}
+
+	ignored, err := new(JointMapper).Init(legServoMap(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upper := commandsByAlias(ignored.Map(joints))["left-front-upper"]; upper.Limited || !closeTo(upper.Angle, 175.9437, 1e-3) {
+		t.Fatalf("upper joint mapped to %+v with the limits ignored", upper)
+	}
+}
+
+func TestJointMapperConstraints(t *testing.T) {
+	// The lower joint may only fold back as far as the upper joint is raised
+	servoMap := legServoMap(
+		structs.JointConstraint{
+			Joint:     consts.JointLower,
+			DependsOn: consts.JointUpper,
+			Points:    []structs.ConstraintPoint{{Angle: -0.5, Min: -0.2, Max: 0.5}, {Angle: 0.5, Min: -0.8, Max: 0.5}},
+		},
+		structs.JointConstraint{
+			Leg:       consts.LegRightBack,
+			Joint:     consts.JointUpper,
+			DependsOn: consts.JointLower,
+			Points:    []structs.ConstraintPoint{{Angle: 0, Min: -0.1, Max: 0.1}},
+		},
+	)
+
+	jointMapper, err := new(JointMapper).Init(servoMap, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joints := &messages.Joints{
+		LeftFront:  hmath.Vec3{0, 0, -0.6},
+		RightFront: hmath.Vec3{0, 0.5, -0.6},
+		LeftBack:   hmath.Vec3{0, -0.5, -0.6},
+		RightBack:  hmath.Vec3{0, 0.3, 0.2},
+	}
+
+	commands := commandsByAlias(jointMapper.Map(joints))
+
+	expected := map[string]struct {
+		joint   float32
+		limited bool
+	}{
+		"left-front-lower":  {-0.5, true},
+		"right-front-lower": {-0.6, false},
+		"left-back-lower":   {-0.2, true},
+		"right-back-upper":  {0.1, true},
+		"right-back-lower":  {0.2, false},
+		"left-front-upper":  {0, false},
+	}
+
+	for alias, want := range expected {
+		command := commands[alias]
+		angle := FromServoAngle(servoMap.Servos[0], command.Angle)
+
+		if !closeTo(angle, want.joint, 1e-4) || command.Limited != want.limited {
+			t.Errorf("%s mapped to joint angle %f limited %t, expected %f %t", alias, angle, command.Limited,
+				want.joint, want.limited)
+		}
+	}
+}
+
+func TestJointMapperRefusesInvalidMaps(t *testing.T) {
+	invalidLimits := legServoMap()
+	invalidLimits.Servos[0].MaxAngle = 200
+
+	duplicate := legServoMap()
+	duplicate.Servos[1].Mapping = duplicate.Servos[0].Mapping
+
+	invalidConstraint := legServoMap(structs.JointConstraint{Joint: consts.JointLower, DependsOn: consts.JointUpper})
+
+	unmapped := legServoMap()
+	unmapped.Servos[2].Mapping = structs.ServoJointMapping{}
+
+	unknownLeg := legServoMap()
+	unknownLeg.Servos[3].Mapping.Leg = "middle"
+
+	unknownJoint := legServoMap()
+	unknownJoint.Servos[4].Mapping.Joint = "ankle"
+
+	invalidDirection := legServoMap()
+	invalidDirection.Servos[5].Mapping.Direction = 2
+
+	for name, servoMap := range map[string]structs.ServoCalibrationMap{
+		"soft limits": invalidLimits, "duplicate mapping": duplicate, "constraint": invalidConstraint,
+		"missing mapping": unmapped, "leg": unknownLeg, "joint": unknownJoint, "direction": invalidDirection,
+		"empty map": {},
+	} {
+		if _, err := new(JointMapper).Init(servoMap, true); err == nil {
+			t.Errorf("servo map with an invalid %s accepted", name)
+		}
+	}
+}
+
+func TestShippedServoMapLimits(t *testing.T) {
+	servoMapData, err := ioutil.ReadFile("../../builds/ServoMap.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servoMap := structs.ServoCalibrationMap{}
+
+	if err := json.Unmarshal(servoMapData, &servoMap); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range servoMap.Servos {
+		if item.MaxAngle == 0 {
+			t.Errorf("servo %s has no soft limits", item.Alias)
+		}
+
+		if err := CheckSoftLimits(item, float32(item.DefaultPosition)); err != nil {
+			t.Errorf("default position is refused: %s", err)
+		}
+	}
+
+	jointMapper, err := new(JointMapper).Init(servoMap, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leg := func(upper float32, lower float32) *messages.Joints {
+		angles := hmath.Vec3{0, upper, lower}
+		return &messages.Joints{LeftFront: angles, RightFront: angles, LeftBack: angles, RightBack: angles}
+	}
+
+	// The rest pose and the nominal stance of the default geometry are within the limits
+	for name, joints := range map[string]*messages.Joints{
+		"rest pose":       leg(1.5799968, -2.5762062),
+		"nominal stance":  leg(0.77084, -1.30248),
+		"stretched knees": leg(0.77084, -0.5),
+	} {
+		for _, command := range jointMapper.Map(joints) {
+			if command.Limited || command.Clamped {
+				t.Errorf("%s: servo %s limited to %.2f degrees", name, command.Alias, command.Angle)
+			}
+		}
+	}
+
+	// The knee may fold less the further the shoulder is lowered from the rest pose
+	for _, command := range jointMapper.Map(leg(0.77, -2.6)) {
+		item := jointMapper.items[jointMapper.key(consts.LegLeftFront, consts.JointLower)]
+
+		if command.Alias == item.Alias && (!command.Limited || !closeTo(FromServoAngle(item, command.Angle), -2.45, 1e-3)) {
+			t.Errorf("folded knee mapped to %.3f radians, expected to be limited to -2.45", FromServoAngle(item, command.Angle))
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package components
+
+import (
+	"errors"
+	"fmt"
+
+	math "github.com/chewxy/math32"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+// ServoCommand is a servo angle produced by the joint mapper
+type ServoCommand struct {
+	Alias      string
+	JointAngle float32 // requested joint angle in radians
+	Angle      float32 // servo angle in degrees after clamping
+	Clamped    bool    // the angle was clamped into the actuation range
+	Limited    bool    // the angle was changed by a soft limit or joint constraint
+}
+
+// JointMapper converts kinematic joint angles into servo angles using the
+// mapping stored in the servo calibration map. When the limits are enforced the
+// angles are kept within the soft limits of the servos and the joint constraints
+type JointMapper struct {
+	items         map[string]structs.ServoCalibrationItem
+	constraints   map[string][]jointConstraint
+	enforceLimits bool
+}
+
+func (jointMapper *JointMapper) Init(calibrationMap structs.ServoCalibrationMap, enforceLimits bool) (*JointMapper, error) {
+	*jointMapper = JointMapper{
+		items:         map[string]structs.ServoCalibrationItem{},
+		constraints:   map[string][]jointConstraint{},
+		enforceLimits: enforceLimits,
+	}
+
+	for _, item := range calibrationMap.Servos {
+		if err := validateSoftLimits(item); err != nil {
+			return nil, err
+		}
+
+		if err := validateMapping(item); err != nil {
+			return nil, err
+		}
+
+		key := jointMapper.key(item.Mapping.Leg, item.Mapping.Joint)
+
+		if existing, ok := jointMapper.items[key]; ok {
+			return nil, fmt.Errorf("servos %s and %s are both mapped to joint %s", existing.Alias, item.Alias, key)
+		}
+
+		jointMapper.items[key] = item
+	}
+
+	if len(jointMapper.items) == 0 {
+		return nil, errors.New("servo map does not map any joint")
+	}
+
+	for _, constraint := range calibrationMap.Constraints {
+		parsed, err := newJointConstraint(constraint)
+		if err != nil {
+			return nil, err
+		}
+
+		legs := legNames
+		if constraint.Leg != "" {
+			legs = []string{constraint.Leg}
+		}
+
+		for _, leg := range legs {
+			jointMapper.constraints[leg] = append(jointMapper.constraints[leg], parsed)
+		}
+	}
+
+	return jointMapper, nil
+}
+
+// validateMapping checks that the servo is mapped onto a known joint of a known leg
+func validateMapping(item structs.ServoCalibrationItem) error {
+	switch {
+	case item.Mapping.Leg == "" && item.Mapping.Joint == "":
+		return fmt.Errorf("servo %s is not mapped to a joint", item.Alias)
+
+	case indexOf(legNames, item.Mapping.Leg) < 0:
+		return fmt.Errorf("servo %s is mapped to unknown leg %s", item.Alias, item.Mapping.Leg)
+
+	case indexOf(jointNames, item.Mapping.Joint) < 0:
+		return fmt.Errorf("servo %s is mapped to unknown joint %s", item.Alias, item.Mapping.Joint)
+
+	case item.Mapping.Direction > 1 || item.Mapping.Direction < -1:
+		return fmt.Errorf("servo %s has direction %d, expected 1 or -1", item.Alias, item.Mapping.Direction)
+
+	case item.Mapping.GearRatio < 0:
+		return fmt.Errorf("servo %s has a negative gear ratio, use the direction to reverse it", item.Alias)
+	}
+
+	return nil
+}
+
+func (jointMapper *JointMapper) key(leg string, joint string) string {
+	return leg + "." + joint
+}
+
+// ToServoAngle converts a joint angle in radians into a servo angle in degrees. The
+// second return value is true when the angle had to be clamped into the actuation range
+func ToServoAngle(item structs.ServoCalibrationItem, jointAngle float32) (float32, bool) {
+	direction := float32(1.0)
+	if item.Mapping.Direction < 0 {
+		direction = -1.0
+	}
+
+	gearRatio := item.Mapping.GearRatio
+	if gearRatio == 0 {
+		gearRatio = 1.0
+	}
+
+	angle := item.Mapping.ZeroOffset + direction*gearRatio*(jointAngle*180.0/math.Pi)
+
+	if angle < 0 {
+		return 0, true
+	}
+
+	if angle > float32(item.ActuationRange) {
+		return float32(item.ActuationRange), true
+	}
+
+	return angle, false
+}
+
+// Map converts every joint in the message into a servo command. Joints that have no
+// servo mapped to them are skipped
+func (jointMapper *JointMapper) Map(joints *messages.Joints) []ServoCommand {
+	legs := map[string]hmath.Vec3{
+		consts.LegLeftFront:  joints.LeftFront,
+		consts.LegRightFront: joints.RightFront,
+		consts.LegLeftBack:   joints.LeftBack,
+		consts.LegRightBack:  joints.RightBack,
+	}
+
+	commands := make([]ServoCommand, 0, len(jointMapper.items))
+
+	for _, leg := range legNames {
+		requested := legs[leg]
+		limited := jointMapper.limitLeg(leg, requested)
+
+		for i, joint := range jointNames {
+			item, ok := jointMapper.items[jointMapper.key(leg, joint)]
+			if !ok {
+				continue
+			}
+
+			angle, clamped := ToServoAngle(item, limited[i])
+
+			if jointMapper.enforceLimits {
+				// Guards against the rounding of the round trip through the joint angle
+				angle, _ = ClampSoftLimits(item, angle)
+			}
+
+			commands = append(commands, ServoCommand{
+				Alias:      item.Alias,
+				JointAngle: requested[i],
+				Angle:      angle,
+				Clamped:    clamped,
+				Limited:    limited[i] != requested[i],
+			})
+		}
+	}
+
+	return commands
+}
+
+// limitLeg returns the joint angles of the leg moved into the soft limits of their servos
+// and then into the joint constraints of the leg, the constraints see the joints they
+// depend on where their servos can actually go
+func (jointMapper *JointMapper) limitLeg(leg string, angles hmath.Vec3) hmath.Vec3 {
+	if !jointMapper.enforceLimits {
+		return angles
+	}
+
+	jointMapper.softLimitLeg(leg, &angles)
+
+	changed := false
+	for _, constraint := range jointMapper.constraints[leg] {
+		changed = constraint.apply(&angles) || changed
+	}
+
+	// A constraint can push a joint back out of the soft limits of its servo
+	if changed {
+		jointMapper.softLimitLeg(leg, &angles)
+	}
+
+	return angles
+}
+
+func (jointMapper *JointMapper) softLimitLeg(leg string, angles *hmath.Vec3) {
+	for i, joint := range jointNames {
+		item, ok := jointMapper.items[jointMapper.key(leg, joint)]
+		if !ok {
+			continue
+		}
+
+		angle, _ := ToServoAngle(item, angles[i])
+
+		if limitedAngle, limited := ClampSoftLimits(item, angle); limited {
+			angles[i] = FromServoAngle(item, limitedAngle)
+		}
+	}
+}
+
+// FromServoAngle converts a servo angle in degrees back into a joint angle in radians
+func FromServoAngle(item structs.ServoCalibrationItem, angle float32) float32 {
+	direction := float32(1.0)
+	if item.Mapping.Direction < 0 {
+		direction = -1.0
+	}
+
+	gearRatio := item.Mapping.GearRatio
+	if gearRatio == 0 {
+		gearRatio = 1.0
+	}
+
+	return ((angle - item.Mapping.ZeroOffset) / (direction * gearRatio)) * math.Pi / 180.0
+}
@@ -0,0 +1,59 @@
+package components
+
+import (
+	pca9685 "github.com/r4stl1n/micro-hal/code/pkg/drivers"
+)
+
+// ServoFrame collects the positions of several servos and writes them with a single
+// transaction per pca9685, so every servo in the frame starts its new pulse in the same
+// pwm cycle
+type ServoFrame struct {
+	channels map[*pca9685.PCA9685]map[int]pca9685.PCA9685Channel
+}
+
+func (servoFrame *ServoFrame) Init() *ServoFrame {
+	*servoFrame = ServoFrame{
+		channels: map[*pca9685.PCA9685]map[int]pca9685.PCA9685Channel{},
+	}
+
+	return servoFrame
+}
+
+// Degrees adds the angle of the servo to the frame. Must be in the range `0` to `Range`.
+func (servoFrame *ServoFrame) Degrees(servo *Servo, angle float32) error {
+	ticks, err := servo.degreesTicks(angle)
+	if err != nil {
+		return err
+	}
+
+	servoFrame.set(servo, ticks)
+
+	return nil
+}
+
+// Reset adds turning off the channel of the servo to the frame
+func (servoFrame *ServoFrame) Reset(servo *Servo) {
+	servoFrame.set(servo, 0)
+}
+
+// Commit writes the frame and empties it
+func (servoFrame *ServoFrame) Commit() error {
+	channels := servoFrame.channels
+	servoFrame.channels = map[*pca9685.PCA9685]map[int]pca9685.PCA9685Channel{}
+
+	for pca, values := range channels {
+		if err := pca.SetChannels(values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (servoFrame *ServoFrame) set(servo *Servo, ticks int) {
+	if _, ok := servoFrame.channels[servo.pca]; !ok {
+		servoFrame.channels[servo.pca] = map[int]pca9685.PCA9685Channel{}
+	}
+
+	servoFrame.channels[servo.pca][servo.channel] = pca9685.PCA9685Channel{On: 0, Off: ticks}
+}
@@ -0,0 +1,341 @@
+package components
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	math "github.com/chewxy/math32"
+)
+
+// MotionProfile selects how a servo eases between two angles
+type MotionProfile string
+
+const (
+	ProfileLinear      MotionProfile = "linear"       // constant velocity, limited by the max velocity only
+	ProfileTrapezoidal MotionProfile = "trapezoidal"  // constant acceleration up to the max velocity and back down
+	ProfileMinimumJerk MotionProfile = "minimum-jerk" // smooth fifth order polynomial
+)
+
+// ParseMotionProfile returns the profile with the given name
+func ParseMotionProfile(name string) (MotionProfile, error) {
+	switch profile := MotionProfile(name); profile {
+	case ProfileLinear, ProfileTrapezoidal, ProfileMinimumJerk:
+		return profile, nil
+	default:
+		return "", fmt.Errorf("unknown motion profile %s", name)
+	}
+}
+
+// MotionLimits bounds the moves of the motion layer, a zero limit is not enforced
+type MotionLimits struct {
+	MaxVelocity     float32 // degrees per second
+	MaxAcceleration float32 // degrees per second squared, not used by the linear profile
+	Profile         MotionProfile
+}
+
+type servoMove struct {
+	from float32
+	to   float32
+}
+
+// servoGroupMove moves one or more servos over the same duration so they all arrive at
+// their target at the same time
+type servoGroupMove struct {
+	moves     map[*Servo]servoMove
+	profile   MotionProfile
+	shape     float32 // fraction of the duration spent accelerating for the trapezoidal profile
+	startTime time.Time
+	duration  time.Duration
+}
+
+// ServoMotion moves servos to target angles within the motion limits instead of jumping
+// straight to them. The moves are stepped by Run on a single ticker shared by every servo.
+// It is meant for moves the control loop does not plan, like the startup move. Streamed
+// joint commands are already smooth and are written directly, SetPosition keeps the motion
+// layer aware of where they left the servos
+type ServoMotion struct {
+	limits MotionLimits
+	rate   float32
+
+	mutex     sync.Mutex
+	groups    []*servoGroupMove
+	positions map[*Servo]float32
+}
+
+// Init creates the motion layer, rate is the step rate of Run in Hz
+func (servoMotion *ServoMotion) Init(limits MotionLimits, rate float32) *ServoMotion {
+	if limits.Profile == "" {
+		limits.Profile = ProfileMinimumJerk
+	}
+
+	*servoMotion = ServoMotion{
+		limits:    limits,
+		rate:      rate,
+		positions: map[*Servo]float32{},
+	}
+
+	return servoMotion
+}
+
+// SetPosition records the angle the servo is at without moving it and cancels its
+// running move. It is used when the servo was driven directly
+func (servoMotion *ServoMotion) SetPosition(servo *Servo, angle float32) {
+	servoMotion.mutex.Lock()
+	defer servoMotion.mutex.Unlock()
+
+	servoMotion.cancel(servo)
+	servoMotion.positions[servo] = angle
+}
+
+// Forget drops the known angle of the servo and cancels its running move, the next move
+// of the servo jumps straight to its target
+func (servoMotion *ServoMotion) Forget(servo *Servo) {
+	servoMotion.mutex.Lock()
+	defer servoMotion.mutex.Unlock()
+
+	servoMotion.cancel(servo)
+	delete(servoMotion.positions, servo)
+}
+
+// Position returns the last angle written to the servo by the motion layer
+func (servoMotion *ServoMotion) Position(servo *Servo) (float32, bool) {
+	servoMotion.mutex.Lock()
+	defer servoMotion.mutex.Unlock()
+
+	angle, ok := servoMotion.positions[servo]
+
+	return angle, ok
+}
+
+// Busy returns true while any move is running
+func (servoMotion *ServoMotion) Busy() bool {
+	servoMotion.mutex.Lock()
+	defer servoMotion.mutex.Unlock()
+
+	return len(servoMotion.groups) > 0
+}
+
+// Move starts moving the servo to the angle
+func (servoMotion *ServoMotion) Move(servo *Servo, angle float32) error {
+	return servoMotion.MoveGroup(map[*Servo]float32{servo: angle})
+}
+
+// MoveGroup starts moving every servo to its target angle. The duration is set by the
+// servo with the longest move so the whole group finishes at the same time. Servos with
+// no known angle are driven straight to their target
+func (servoMotion *ServoMotion) MoveGroup(targets map[*Servo]float32) error {
+	servoMotion.mutex.Lock()
+	defer servoMotion.mutex.Unlock()
+
+	group := &servoGroupMove{
+		moves:     map[*Servo]servoMove{},
+		profile:   servoMotion.limits.Profile,
+		startTime: time.Now(),
+	}
+
+	distance := float32(0.0)
+
+	for servo, angle := range targets {
+		if angle < 0 || angle > float32(servo.options.ActuationRange) {
+			return fmt.Errorf("angle %.2f for channel %d is out of range", angle, servo.channel)
+		}
+	}
+
+	// Servos without a known angle are written together straight away
+	frame := new(ServoFrame).Init()
+	angles := map[*Servo]float32{}
+
+	for servo, angle := range targets {
+		servoMotion.cancel(servo)
+
+		from, known := servoMotion.positions[servo]
+		if !known {
+			if err := frame.Degrees(servo, angle); err != nil {
+				return err
+			}
+
+			angles[servo] = angle
+
+			continue
+		}
+
+		group.moves[servo] = servoMove{from: from, to: angle}
+		distance = math.Max(distance, math.Abs(angle-from))
+	}
+
+	if err := servoMotion.write(frame, angles); err != nil {
+		return err
+	}
+
+	if len(group.moves) == 0 || distance == 0 {
+		return nil
+	}
+
+	duration, shape := servoMotion.plan(distance)
+	group.duration = time.Duration(duration * float32(time.Second))
+	group.shape = shape
+
+	servoMotion.groups = append(servoMotion.groups, group)
+
+	return nil
+}
+
+// Step writes the angle every running move has at the given time to its servo and
+// removes the moves that are done. The angles of all servos are written together
+func (servoMotion *ServoMotion) Step(currentTime time.Time) error {
+	servoMotion.mutex.Lock()
+	defer servoMotion.mutex.Unlock()
+
+	var stepError error
+
+	frame := new(ServoFrame).Init()
+	angles := map[*Servo]float32{}
+
+	running := servoMotion.groups[:0]
+
+	for _, group := range servoMotion.groups {
+		progress := float32(1.0)
+		if group.duration > 0 {
+			progress = math.Min(float32(currentTime.Sub(group.startTime).Seconds()/group.duration.Seconds()), 1.0)
+		}
+
+		fraction := ease(group.profile, group.shape, progress)
+
+		for servo, move := range group.moves {
+			angle := move.from + ((move.to - move.from) * fraction)
+
+			if err := frame.Degrees(servo, angle); err != nil {
+				stepError = err
+				continue
+			}
+
+			angles[servo] = angle
+		}
+
+		if progress < 1.0 {
+			running = append(running, group)
+		}
+	}
+
+	servoMotion.groups = running
+
+	if err := servoMotion.write(frame, angles); err != nil {
+		stepError = err
+	}
+
+	return stepError
+}
+
+// Run steps the moves at the configured rate until the stop channel is closed
+func (servoMotion *ServoMotion) Run(stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(time.Duration(float32(time.Second) / servoMotion.rate))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case tickTime := <-ticker.C:
+			if err := servoMotion.Step(tickTime); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// plan returns the duration in seconds of a move over the distance and the share of it
+// spent accelerating for the trapezoidal profile
+func (servoMotion *ServoMotion) plan(distance float32) (float32, float32) {
+	maxVelocity := servoMotion.limits.MaxVelocity
+	maxAcceleration := servoMotion.limits.MaxAcceleration
+
+	switch servoMotion.limits.Profile {
+	case ProfileLinear:
+		if maxVelocity <= 0 {
+			return 0, 0
+		}
+
+		return distance / maxVelocity, 0
+
+	case ProfileTrapezoidal:
+		switch {
+		case maxVelocity <= 0 && maxAcceleration <= 0:
+			return 0, 0
+
+		case maxAcceleration <= 0:
+			return distance / maxVelocity, 0
+
+		case maxVelocity <= 0 || distance < (maxVelocity*maxVelocity)/maxAcceleration:
+			// The max velocity is never reached so the profile is a triangle
+			return 2.0 * math.Sqrt(distance/maxAcceleration), 0.5
+
+		default:
+			duration := (distance / maxVelocity) + (maxVelocity / maxAcceleration)
+			return duration, (maxVelocity / maxAcceleration) / duration
+		}
+
+	default:
+		// The peak velocity of the minimum jerk profile is 1.875 and the peak acceleration
+		// 5.7735 times the average
+		duration := float32(0.0)
+
+		if maxVelocity > 0 {
+			duration = 1.875 * distance / maxVelocity
+		}
+
+		if maxAcceleration > 0 {
+			duration = math.Max(duration, math.Sqrt(5.7735*distance/maxAcceleration))
+		}
+
+		return duration, 0
+	}
+}
+
+// cancel removes the servo from its running move, it must be called with the mutex held
+func (servoMotion *ServoMotion) cancel(servo *Servo) {
+	for _, group := range servoMotion.groups {
+		delete(group.moves, servo)
+	}
+}
+
+// write commits the frame and records the angles written by it, it must be called with
+// the mutex held
+func (servoMotion *ServoMotion) write(frame *ServoFrame, angles map[*Servo]float32) error {
+	if err := frame.Commit(); err != nil {
+		return err
+	}
+
+	for servo, angle := range angles {
+		servoMotion.positions[servo] = angle
+	}
+
+	return nil
+}
+
+// ease maps the progress of a move between 0 and 1 onto the travelled fraction of its
+// distance
+func ease(profile MotionProfile, shape float32, progress float32) float32 {
+	switch profile {
+	case ProfileLinear:
+		return progress
+
+	case ProfileTrapezoidal:
+		if shape <= 0 {
+			return progress
+		}
+
+		switch {
+		case progress < shape:
+			return 0.5 * progress * progress / (shape * (1.0 - shape))
+		case progress > 1.0-shape:
+			return 1.0 - (0.5 * (1.0 - progress) * (1.0 - progress) / (shape * (1.0 - shape)))
+		default:
+			return (progress - (shape / 2.0)) / (1.0 - shape)
+		}
+
+	default:
+		return progress * progress * progress * (10.0 - (15.0 * progress) + (6.0 * progress * progress))
+	}
+}
@@ -0,0 +1,261 @@
+package components
+
+import (
+	"testing"
+	"time"
+
+	math "github.com/chewxy/math32"
+	pca9685 "github.com/r4stl1n/micro-hal/code/pkg/drivers"
+	i2c "github.com/r4stl1n/micro-hal/code/pkg/drivers/base"
+)
+
+// newFakeServos returns servos on the first channels of a pca9685 on a fake i2c device
+func newFakeServos(t *testing.T, count int) ([]*Servo, *i2c.FakeDevice) {
+	device := new(i2c.FakeDevice).Init(pca9685.DefaultPCA9685Address)
+
+	pca, err := new(pca9685.PCA9685).Init(device, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device.ClearTransactions()
+
+	servos := make([]*Servo, count)
+
+	for i := range servos {
+		servos[i] = new(Servo).Init(pca, i, &ServoOptions{
+			ActuationRange: ServoRangeDef,
+			MinPulse:       ServoMinPulseDef,
+			MaxPulse:       ServoMaxPulseDef,
+		})
+	}
+
+	return servos, device
+}
+
+func closeTo(a float32, b float32, tolerance float32) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestServoMotionPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   MotionLimits
+		distance float32
+		duration float32
+		shape    float32
+	}{
+		{"linear", MotionLimits{MaxVelocity: 90, Profile: ProfileLinear}, 45, 0.5, 0},
+		{"linear ignores acceleration", MotionLimits{MaxVelocity: 90, MaxAcceleration: 1, Profile: ProfileLinear}, 90, 1, 0},
+		{"linear without limit", MotionLimits{Profile: ProfileLinear}, 90, 0, 0},
+		{"trapezoidal triangle", MotionLimits{MaxVelocity: 90, MaxAcceleration: 360, Profile: ProfileTrapezoidal}, 10,
+			2 * math.Sqrt(10.0/360.0), 0.5},
+		{"trapezoidal plateau", MotionLimits{MaxVelocity: 90, MaxAcceleration: 360, Profile: ProfileTrapezoidal}, 90,
+			1.25, 0.2},
+		{"trapezoidal without acceleration", MotionLimits{MaxVelocity: 90, Profile: ProfileTrapezoidal}, 90, 1, 0},
+		{"trapezoidal without velocity", MotionLimits{MaxAcceleration: 360, Profile: ProfileTrapezoidal}, 90,
+			1, 0.5},
+		{"minimum jerk velocity bound", MotionLimits{MaxVelocity: 90, MaxAcceleration: 3600, Profile: ProfileMinimumJerk}, 90,
+			1.875, 0},
+		{"minimum jerk acceleration bound", MotionLimits{MaxVelocity: 90, MaxAcceleration: 360, Profile: ProfileMinimumJerk}, 10,
+			math.Sqrt(5.7735 * 10.0 / 360.0), 0},
+		{"minimum jerk without limit", MotionLimits{Profile: ProfileMinimumJerk}, 90, 0, 0},
+	}
+
+	for _, test := range tests {
+		duration, shape := new(ServoMotion).Init(test.limits, 50).plan(test.distance)
+
+		if !closeTo(duration, test.duration, 1e-5) || !closeTo(shape, test.shape, 1e-5) {
+			t.Errorf("%s: planned %f s with shape %f, expected %f s with shape %f", test.name, duration, shape,
+				test.duration, test.shape)
+		}
+	}
+}
+
+// TestServoMotionLimits samples every profile and checks that the peak velocity and
+// acceleration stay within the limits and one of them is reached
+func TestServoMotionLimits(t *testing.T) {
+	const steps = 400
+
+	limits := MotionLimits{MaxVelocity: 90, MaxAcceleration: 360}
+
+	for _, profile := range []MotionProfile{ProfileLinear, ProfileTrapezoidal, ProfileMinimumJerk} {
+		limits.Profile = profile
+		servoMotion := new(ServoMotion).Init(limits, 50)
+
+		for _, distance := range []float32{1, 10, 22.5, 45, 90, 180} {
+			duration, shape := servoMotion.plan(distance)
+			dt := float64(duration) / steps
+
+			position := func(step int) float64 {
+				return float64(ease(profile, shape, float32(step)/steps) * distance)
+			}
+
+			peakVelocity, peakAcceleration := 0.0, 0.0
+
+			for step := 1; step < steps; step++ {
+				velocity := (position(step+1) - position(step-1)) / (2 * dt)
+				acceleration := (position(step+1) - (2 * position(step)) + position(step-1)) / (dt * dt)
+
+				peakVelocity = maxFloat64(peakVelocity, velocity)
+				peakAcceleration = maxFloat64(peakAcceleration, acceleration)
+			}
+
+			if position(0) != 0 || !closeTo(float32(position(steps)), distance, 1e-4) {
+				t.Fatalf("%s over %.1f degrees moved from %f to %f", profile, distance, position(0), position(steps))
+			}
+
+			if peakVelocity > 1.01*float64(limits.MaxVelocity) {
+				t.Errorf("%s over %.1f degrees peaks at %.1f deg/s", profile, distance, peakVelocity)
+			}
+
+			if profile != ProfileLinear && peakAcceleration > 1.02*float64(limits.MaxAcceleration) {
+				t.Errorf("%s over %.1f degrees peaks at %.1f deg/s2", profile, distance, peakAcceleration)
+			}
+
+			// The move must not be slower than needed, one of the limits is reached
+			if profile != ProfileLinear && peakVelocity < 0.98*float64(limits.MaxVelocity) &&
+				peakAcceleration < 0.95*float64(limits.MaxAcceleration) {
+				t.Errorf("%s over %.1f degrees reaches neither limit, %.1f deg/s and %.1f deg/s2", profile, distance,
+					peakVelocity, peakAcceleration)
+			}
+		}
+	}
+}
+
+func maxFloat64(a float64, b float64) float64 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func TestServoMotionEase(t *testing.T) {
+	for _, profile := range []MotionProfile{ProfileLinear, ProfileTrapezoidal, ProfileMinimumJerk} {
+		for _, shape := range []float32{0, 0.2, 0.5} {
+			previous := float32(0.0)
+
+			for step := 0; step <= 100; step++ {
+				fraction := ease(profile, shape, float32(step)/100)
+
+				if fraction < previous-1e-6 {
+					t.Fatalf("%s with shape %.1f moves backwards at %d%%", profile, shape, step)
+				}
+
+				previous = fraction
+			}
+
+			if ease(profile, shape, 0) != 0 || !closeTo(ease(profile, shape, 1), 1, 1e-6) {
+				t.Fatalf("%s with shape %.1f does not start at 0 and end at 1", profile, shape)
+			}
+		}
+	}
+
+	// Minimum jerk is symmetric and passes half way at half time
+	if !closeTo(ease(ProfileMinimumJerk, 0, 0.5), 0.5, 1e-6) {
+		t.Fatal("minimum jerk is not half way at half time")
+	}
+}
+
+func TestServoMotionMoveGroup(t *testing.T) {
+	servos, device := newFakeServos(t, 2)
+
+	servoMotion := new(ServoMotion).Init(MotionLimits{MaxVelocity: 90, Profile: ProfileLinear}, 50)
+
+	// Servos without a known angle jump to their target in a single transaction
+	if err := servoMotion.MoveGroup(map[*Servo]float32{servos[0]: 90, servos[1]: 90}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(device.Transactions()) != 1 || servoMotion.Busy() {
+		t.Fatalf("unknown servos were not written straight away, %d transactions", len(device.Transactions()))
+	}
+
+	if err := servoMotion.MoveGroup(map[*Servo]float32{servos[0]: 180, servos[1]: 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(servoMotion.groups) != 1 || servoMotion.groups[0].duration != time.Second {
+		t.Fatalf("group is not planned over the longest move of 90 degrees")
+	}
+
+	start := servoMotion.groups[0].startTime
+	device.ClearTransactions()
+
+	// Both servos are half way at half time
+	if err := servoMotion.Step(start.Add(500 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	for servo, expected := range map[*Servo]float32{servos[0]: 135, servos[1]: 75} {
+		if angle, _ := servoMotion.Position(servo); !closeTo(angle, expected, 1e-3) {
+			t.Fatalf("servo on channel %d is at %f half way, expected %f", servo.channel, angle, expected)
+		}
+	}
+
+	if len(device.Transactions()) != 1 || !servoMotion.Busy() {
+		t.Fatalf("step wrote %d transactions", len(device.Transactions()))
+	}
+
+	if err := servoMotion.Step(start.Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	for servo, expected := range map[*Servo]float32{servos[0]: 180, servos[1]: 60} {
+		if angle, _ := servoMotion.Position(servo); angle != expected {
+			t.Fatalf("servo on channel %d ended at %f, expected %f", servo.channel, angle, expected)
+		}
+
+		expectedTicks, _ := servo.degreesTicks(expected)
+
+		if servo.Ticks() != expectedTicks {
+			t.Fatalf("servo on channel %d has %d ticks, expected %d", servo.channel, servo.Ticks(), expectedTicks)
+		}
+	}
+
+	if servoMotion.Busy() {
+		t.Fatal("move still running after its duration")
+	}
+}
+
+func TestServoMotionRejectsOutOfRange(t *testing.T) {
+	servos, device := newFakeServos(t, 2)
+
+	servoMotion := new(ServoMotion).Init(MotionLimits{}, 50)
+
+	if err := servoMotion.MoveGroup(map[*Servo]float32{servos[0]: 90, servos[1]: 181}); err == nil {
+		t.Fatal("expected an error for an angle past the actuation range")
+	}
+
+	if len(device.Transactions()) != 0 {
+		t.Fatal("servos moved although the group was refused")
+	}
+}
+
+func TestServoMotionSetPositionCancelsMove(t *testing.T) {
+	servos, _ := newFakeServos(t, 1)
+
+	servoMotion := new(ServoMotion).Init(MotionLimits{MaxVelocity: 90}, 50)
+	servoMotion.SetPosition(servos[0], 0)
+
+	if err := servoMotion.Move(servos[0], 90); err != nil {
+		t.Fatal(err)
+	}
+
+	servoMotion.SetPosition(servos[0], 45)
+
+	if err := servoMotion.Step(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if angle, _ := servoMotion.Position(servos[0]); angle != 45 {
+		t.Fatalf("cancelled move drove the servo to %f", angle)
+	}
+
+	servoMotion.Forget(servos[0])
+
+	if _, known := servoMotion.Position(servos[0]); known {
+		t.Fatal("forgotten servo still has a position")
+	}
+}
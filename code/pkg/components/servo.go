@@ -3,6 +3,8 @@ package components
 import (
 	"fmt"
 
+	math "github.com/chewxy/math32"
+
 	pca9685 "github.com/r4stl1n/micro-hal/code/pkg/drivers"
 )
 
@@ -39,31 +41,100 @@ func (servo *Servo) Init(pca *pca9685.PCA9685, servoChannel int, serverOptions *
 
 // Angle in degrees. Must be in the range `0` to `Range`.
 func (servo *Servo) Angle(angle int) (err error) {
-	if angle < 0 || angle > servo.options.ActuationRange {
-		return fmt.Errorf("Angle out of range")
+	return servo.Degrees(float32(angle))
+}
+
+// Degrees sets the angle in degrees with sub degree precision. Must be in the range
+// `0` to `Range`.
+func (servo *Servo) Degrees(angle float32) error {
+	ticks, err := servo.degreesTicks(angle)
+	if err != nil {
+		return err
 	}
-	return servo.Fraction(float32(angle) / float32(servo.options.ActuationRange))
+
+	return servo.setTicks(ticks)
+}
+
+// Radians sets the angle in radians. Must be in the range `0` to `Range` in degrees.
+func (servo *Servo) Radians(angle float32) error {
+	return servo.Degrees(angle * 180.0 / math.Pi)
 }
 
 // Fraction as pulse width expressed between 0.0 `MinPulse` and 1.0 `MaxPulse`.
 // For conventional servos, corresponds to the servo position as a fraction
 // of the actuation range.
 func (servo *Servo) Fraction(fraction float32) (err error) {
-	if fraction < 0.0 || fraction > 1.0 {
-		return fmt.Errorf("must be 0.0 to 1.0")
+	ticks, err := servo.fractionTicks(fraction)
+	if err != nil {
+		return err
 	}
 
-	freq := servo.pca.GetFreq()
+	return servo.setTicks(ticks)
+}
 
-	minDuty := servo.options.MinPulse * freq / 1000000 * 0xFFFF
-	maxDuty := servo.options.MaxPulse * freq / 1000000 * 0xFFFF
-	dutyRange := maxDuty - minDuty
-	dutyCycle := (int(minDuty+fraction*dutyRange) + 1) >> 4
+// Pulse sets the pulse width in microseconds rounded to the nearest pca9685 tick. The
+// pulse is not limited to `MinPulse` and `MaxPulse` so it can be used for calibration
+func (servo *Servo) Pulse(pulse float32) error {
+	ticks, err := servo.pulseTicks(pulse)
+	if err != nil {
+		return err
+	}
 
-	return servo.pca.SetChannel(int(servo.channel), 0, dutyCycle)
+	return servo.setTicks(ticks)
+}
+
+// Resolution returns the angle in degrees moved by a single pca9685 tick
+func (servo *Servo) Resolution() float32 {
+	ticks := (servo.options.MaxPulse - servo.options.MinPulse) * servo.pca.GetOptions().StepCount / servo.period()
+
+	if ticks <= 0 {
+		return 0
+	}
+
+	return float32(servo.options.ActuationRange) / ticks
+}
+
+// period returns the length of a pwm cycle in microseconds
+func (servo *Servo) period() float32 {
+	return 1000000.0 / servo.pca.GetFreq()
 }
 
 // Reset channel
 func (servo *Servo) Reset() (err error) {
-	return servo.pca.SetChannel(servo.channel, 0, 0)
+	return servo.setTicks(0)
+}
+
+// Ticks returns the off count last written to the channel
+func (servo *Servo) Ticks() int {
+	return servo.pca.Channel(servo.channel).Off
+}
+
+func (servo *Servo) degreesTicks(angle float32) (int, error) {
+	if angle < 0 || angle > float32(servo.options.ActuationRange) {
+		return 0, fmt.Errorf("angle %.2f out of range 0 to %d", angle, servo.options.ActuationRange)
+	}
+
+	return servo.fractionTicks(angle / float32(servo.options.ActuationRange))
+}
+
+func (servo *Servo) fractionTicks(fraction float32) (int, error) {
+	if fraction < 0.0 || fraction > 1.0 {
+		return 0, fmt.Errorf("must be 0.0 to 1.0")
+	}
+
+	return servo.pulseTicks(servo.options.MinPulse + (fraction * (servo.options.MaxPulse - servo.options.MinPulse)))
+}
+
+func (servo *Servo) pulseTicks(pulse float32) (int, error) {
+	period := servo.period()
+
+	if pulse < 0 || pulse >= period {
+		return 0, fmt.Errorf("pulse %.1fus out of range 0 to %.1fus", pulse, period)
+	}
+
+	return int(math.Round(pulse * servo.pca.GetOptions().StepCount / period)), nil
+}
+
+func (servo *Servo) setTicks(ticks int) error {
+	return servo.pca.SetChannel(servo.channel, 0, ticks)
 }
@@ -0,0 +1,130 @@
+package components
+
+import (
+	"testing"
+)
+
+// At 50Hz a pca9685 tick is 20000us / 4096 = 4.8828125us, the default pulse range of
+// 500us to 2500us covers 409.6 ticks
+func TestServoDegreesTicks(t *testing.T) {
+	servos, _ := newFakeServos(t, 1)
+	servo := servos[0]
+
+	tests := []struct {
+		angle float32
+		ticks int
+		fails bool
+	}{
+		{0, 102, false},    // 102.4
+		{45, 205, false},   // 204.8 rounds up
+		{90, 307, false},   // 307.2
+		{0.2, 103, false},  // 102.86, truncating would lose the step
+		{0.04, 102, false}, // 102.49 is closer to the tick below
+		{180, 512, false},  // 512.0
+		{-0.1, 0, true},
+		{180.1, 0, true},
+	}
+
+	for _, test := range tests {
+		ticks, err := servo.degreesTicks(test.angle)
+
+		if (err != nil) != test.fails {
+			t.Errorf("%.2f degrees returned error %v", test.angle, err)
+			continue
+		}
+
+		if !test.fails && ticks != test.ticks {
+			t.Errorf("%.2f degrees mapped to %d ticks, expected %d", test.angle, ticks, test.ticks)
+		}
+	}
+}
+
+func TestServoPulseTicks(t *testing.T) {
+	servos, _ := newFakeServos(t, 1)
+	servo := servos[0]
+
+	tests := []struct {
+		pulse float32
+		ticks int
+		fails bool
+	}{
+		{0, 0, false},
+		{1500, 307, false},   // 307.2
+		{1502.5, 308, false}, // 307.71
+		{400, 82, false},     // 81.92, calibration pulses can leave the servo range
+		{19995, 4095, false}, // 4094.98
+		{20000, 0, true},     // a whole pwm cycle
+		{-1, 0, true},
+	}
+
+	for _, test := range tests {
+		ticks, err := servo.pulseTicks(test.pulse)
+
+		if (err != nil) != test.fails {
+			t.Errorf("%.1fus returned error %v", test.pulse, err)
+			continue
+		}
+
+		if !test.fails && ticks != test.ticks {
+			t.Errorf("%.1fus mapped to %d ticks, expected %d", test.pulse, ticks, test.ticks)
+		}
+	}
+}
+
+func TestServoResolution(t *testing.T) {
+	servos, _ := newFakeServos(t, 2)
+
+	if resolution := servos[0].Resolution(); !closeTo(resolution, 180.0/409.6, 1e-6) {
+		t.Errorf("180 degree servo moves %f degrees per tick, expected %f", resolution, 180.0/409.6)
+	}
+
+	servos[1].options = &ServoOptions{ActuationRange: 270, MinPulse: 500, MaxPulse: 2500}
+
+	if resolution := servos[1].Resolution(); !closeTo(resolution, 270.0/409.6, 1e-6) {
+		t.Errorf("270 degree servo moves %f degrees per tick, expected %f", resolution, 270.0/409.6)
+	}
+
+	servos[1].options = &ServoOptions{ActuationRange: 180, MinPulse: 1500, MaxPulse: 1500}
+
+	if resolution := servos[1].Resolution(); resolution != 0 {
+		t.Errorf("servo without a pulse range moves %f degrees per tick, expected 0", resolution)
+	}
+}
+
+func TestServoWritesTheRoundedTicks(t *testing.T) {
+	servos, _ := newFakeServos(t, 1)
+	servo := servos[0]
+
+	if err := servo.Degrees(45); err != nil {
+		t.Fatal(err)
+	}
+
+	if ticks := servo.Ticks(); ticks != 205 {
+		t.Fatalf("servo at 45 degrees has %d ticks, expected 205", ticks)
+	}
+
+	// A refused angle leaves the channel as it was
+	if err := servo.Degrees(200); err == nil {
+		t.Fatal("angle past the actuation range accepted")
+	}
+
+	if ticks := servo.Ticks(); ticks != 205 {
+		t.Fatalf("refused angle changed the ticks to %d", ticks)
+	}
+
+	if err := servo.Pulse(1502.5); err != nil {
+		t.Fatal(err)
+	}
+
+	if ticks := servo.Ticks(); ticks != 308 {
+		t.Fatalf("servo at 1502.5us has %d ticks, expected 308", ticks)
+	}
+
+	if err := servo.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ticks := servo.Ticks(); ticks != 0 {
+		t.Fatalf("reset servo has %d ticks, expected 0", ticks)
+	}
+}
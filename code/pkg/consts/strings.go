@@ -1,10 +1,39 @@
 package consts
 
 const (
-	MQMasterChannel   = "halmicro.>"
-	MQNodePrefix      = "halmicro.node."
-	MQPoseGetChannel  = "halmicro.pose.get"
-	MQPoseSetChannel  = "halmicro.pose.set"
-	MQJointGetChannel = "halmicro.joints.get"
-	MQJointSetChannel = "halmicro.joints.set"
+	MQMasterChannel        = "halmicro.>"
+	MQNodePrefix           = "halmicro.node."
+	MQPoseGetChannel       = "halmicro.pose.get"
+	MQPoseSetChannel       = "halmicro.pose.set"
+	MQJointGetChannel      = "halmicro.joints.get"
+	MQJointSetChannel      = "halmicro.joints.set"
+	MQJointStateChannel    = "halmicro.joints.state"
+	MQCmdVelChannel        = "halmicro.cmd_vel"
+	MQCmdVelAppliedChannel = "halmicro.cmd_vel.applied"
+	MQSimJointsChannel     = "halmicro.sim.joints"
+	MQGaitSetChannel       = "halmicro.gait.set"
+	MQWatchdogChannel      = "halmicro.watchdog"
+	MQEStopChannel         = "halmicro.estop"
+	MQEStopResetChannel    = "halmicro.estop.reset"
+	MQEStopGetChannel      = "halmicro.estop.get"
+	MQEStopStateChannel    = "halmicro.estop.state"
+	MQStateChannel         = "halmicro.state"
+	MQStateGetChannel      = "halmicro.state.get"
+	MQStateSetChannel      = "halmicro.state.set"
+)
+
+const (
+	LegLeftFront  = "left-front"
+	LegRightFront = "right-front"
+	LegLeftBack   = "left-back"
+	LegRightBack  = "right-back"
+
+	JointHip   = "hip"
+	JointUpper = "upper"
+	JointLower = "lower"
+)
+
+const (
+	NodeNameController = "controller-node"
+	NodeNameJoints     = "joints-node"
 )
@@ -0,0 +1,41 @@
+package i2c
+
+// Device is a single addressable device on an I2C bus. It is implemented by I2C for
+// real hardware and by FakeDevice for running drivers without hardware attached
+type Device interface {
+	GetAddr() uint8
+	ReadBytes(buf []byte) (int, error)
+	ReadRegBytes(reg byte, n int) ([]byte, int, error)
+	ReadRegU8(reg byte) (byte, error)
+	WriteBytes(buf []byte) (int, error)
+	WriteRegU8(reg byte, value byte) error
+	Close() error
+}
+
+// Bus opens devices on an I2C bus by address
+type Bus interface {
+	Open(addr uint8) (Device, error)
+}
+
+// DevBus is a Bus backed by a /dev/i2c-N character device
+type DevBus struct {
+	dev string
+}
+
+func (devBus *DevBus) Init(dev string) *DevBus {
+	*devBus = DevBus{
+		dev: dev,
+	}
+
+	return devBus
+}
+
+// Open opens a connection to the device at addr
+func (devBus *DevBus) Open(addr uint8) (Device, error) {
+	device, err := new(I2C).Init(addr, devBus.dev, DEFAULT_I2C_ADDRESS)
+	if err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
@@ -0,0 +1,213 @@
+package i2c
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Transaction is a single read or write recorded by a FakeDevice
+type Transaction struct {
+	Write bool
+	Data  []byte
+}
+
+// FakeDevice is an in memory Device backed by a 256 byte register map. Writes set the
+// register pointer from the first byte and store the remaining bytes with auto increment,
+// reads return bytes from the register pointer with auto increment. Every transaction
+// is recorded so drivers can be verified without hardware attached
+type FakeDevice struct {
+	mutex sync.Mutex
+
+	addr      uint8
+	pointer   byte
+	registers [256]byte
+
+	transactions []Transaction
+	closed       bool
+
+	// OnWrite is called with the register and value of every byte stored by a write
+	OnWrite func(reg byte, value byte)
+}
+
+func (fakeDevice *FakeDevice) Init(addr uint8) *FakeDevice {
+	*fakeDevice = FakeDevice{
+		addr: addr,
+	}
+
+	return fakeDevice
+}
+
+// GetAddr return device occupied address in the bus.
+func (fakeDevice *FakeDevice) GetAddr() uint8 {
+	return fakeDevice.addr
+}
+
+// ReadBytes reads len(buf) bytes starting at the register pointer
+func (fakeDevice *FakeDevice) ReadBytes(buf []byte) (int, error) {
+	fakeDevice.mutex.Lock()
+	defer fakeDevice.mutex.Unlock()
+
+	if fakeDevice.closed {
+		return 0, fmt.Errorf("device 0x%x is closed", fakeDevice.addr)
+	}
+
+	for i := range buf {
+		buf[i] = fakeDevice.registers[fakeDevice.pointer]
+		fakeDevice.pointer++
+	}
+
+	fakeDevice.transactions = append(fakeDevice.transactions, Transaction{Write: false, Data: append([]byte{}, buf...)})
+
+	return len(buf), nil
+}
+
+// ReadRegBytes reads n bytes starting from reg
+func (fakeDevice *FakeDevice) ReadRegBytes(reg byte, n int) ([]byte, int, error) {
+	if _, err := fakeDevice.WriteBytes([]byte{reg}); err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, n)
+
+	c, err := fakeDevice.ReadBytes(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return buf, c, nil
+}
+
+// ReadRegU8 reads the byte stored in reg
+func (fakeDevice *FakeDevice) ReadRegU8(reg byte) (byte, error) {
+	buf, _, err := fakeDevice.ReadRegBytes(reg, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	return buf[0], nil
+}
+
+// WriteBytes sets the register pointer to buf[0] and stores the remaining bytes
+func (fakeDevice *FakeDevice) WriteBytes(buf []byte) (int, error) {
+	fakeDevice.mutex.Lock()
+
+	if fakeDevice.closed {
+		fakeDevice.mutex.Unlock()
+		return 0, fmt.Errorf("device 0x%x is closed", fakeDevice.addr)
+	}
+
+	fakeDevice.transactions = append(fakeDevice.transactions, Transaction{Write: true, Data: append([]byte{}, buf...)})
+
+	if len(buf) == 0 {
+		fakeDevice.mutex.Unlock()
+		return 0, nil
+	}
+
+	fakeDevice.pointer = buf[0]
+
+	type write struct {
+		reg   byte
+		value byte
+	}
+
+	writes := make([]write, 0, len(buf)-1)
+
+	for _, value := range buf[1:] {
+		fakeDevice.registers[fakeDevice.pointer] = value
+		writes = append(writes, write{reg: fakeDevice.pointer, value: value})
+		fakeDevice.pointer++
+	}
+
+	onWrite := fakeDevice.OnWrite
+
+	fakeDevice.mutex.Unlock()
+
+	if onWrite != nil {
+		for _, w := range writes {
+			onWrite(w.reg, w.value)
+		}
+	}
+
+	return len(buf), nil
+}
+
+// WriteRegU8 stores value in reg
+func (fakeDevice *FakeDevice) WriteRegU8(reg byte, value byte) error {
+	_, err := fakeDevice.WriteBytes([]byte{reg, value})
+	return err
+}
+
+// Close marks the device as closed, further reads and writes fail
+func (fakeDevice *FakeDevice) Close() error {
+	fakeDevice.mutex.Lock()
+	defer fakeDevice.mutex.Unlock()
+
+	fakeDevice.closed = true
+
+	return nil
+}
+
+// Register returns the value stored in reg without recording a transaction
+func (fakeDevice *FakeDevice) Register(reg byte) byte {
+	fakeDevice.mutex.Lock()
+	defer fakeDevice.mutex.Unlock()
+
+	return fakeDevice.registers[reg]
+}
+
+// SetRegister stores value in reg without recording a transaction
+func (fakeDevice *FakeDevice) SetRegister(reg byte, value byte) {
+	fakeDevice.mutex.Lock()
+	defer fakeDevice.mutex.Unlock()
+
+	fakeDevice.registers[reg] = value
+}
+
+// Transactions returns a copy of every recorded transaction
+func (fakeDevice *FakeDevice) Transactions() []Transaction {
+	fakeDevice.mutex.Lock()
+	defer fakeDevice.mutex.Unlock()
+
+	return append([]Transaction{}, fakeDevice.transactions...)
+}
+
+// ClearTransactions removes every recorded transaction
+func (fakeDevice *FakeDevice) ClearTransactions() {
+	fakeDevice.mutex.Lock()
+	defer fakeDevice.mutex.Unlock()
+
+	fakeDevice.transactions = nil
+}
+
+// FakeBus is an in memory Bus that hands out a FakeDevice per address
+type FakeBus struct {
+	mutex   sync.Mutex
+	devices map[uint8]*FakeDevice
+}
+
+func (fakeBus *FakeBus) Init() *FakeBus {
+	*fakeBus = FakeBus{
+		devices: map[uint8]*FakeDevice{},
+	}
+
+	return fakeBus
+}
+
+// Open returns the fake device at addr creating it when needed
+func (fakeBus *FakeBus) Open(addr uint8) (Device, error) {
+	return fakeBus.Device(addr), nil
+}
+
+// Device returns the fake device at addr creating it when needed
+func (fakeBus *FakeBus) Device(addr uint8) *FakeDevice {
+	fakeBus.mutex.Lock()
+	defer fakeBus.mutex.Unlock()
+
+	device, ok := fakeBus.devices[addr]
+	if !ok {
+		device = new(FakeDevice).Init(addr)
+		fakeBus.devices[addr] = device
+	}
+
+	return device
+}
@@ -95,7 +95,7 @@ type LSM6DS3Data struct {
 
 // LSM6DS3 is a Driver for the LSM6DS3 6-axis Accelerometer Gyroscope Sensor
 type LSM6DS3 struct {
-	i2c     *i2c.I2C
+	i2c     i2c.Device
 	options *LSM6DS3Options
 }
 
@@ -106,7 +106,7 @@ type LSM6DS3Options struct {
 }
 
 // Init creates the new LSM6DS3 driver with specified i2c interface and options
-func (lsm6ds3 *LSM6DS3) Init(i2c *i2c.I2C, options *LSM6DS3Options) (*LSM6DS3, error) {
+func (lsm6ds3 *LSM6DS3) Init(i2c i2c.Device, options *LSM6DS3Options) (*LSM6DS3, error) {
 
 	adr := i2c.GetAddr()
 
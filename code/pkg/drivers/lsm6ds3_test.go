@@ -0,0 +1,123 @@
+package drivers
+
+import (
+	"testing"
+
+	i2c "github.com/r4stl1n/micro-hal/code/pkg/drivers/base"
+)
+
+func newFakeLSM6DS3(t *testing.T) (*LSM6DS3, *i2c.FakeDevice) {
+	device := new(i2c.FakeDevice).Init(DefaultLSM6DS3Address)
+
+	lsm6ds3, err := new(LSM6DS3).Init(device, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device.ClearTransactions()
+
+	return lsm6ds3, device
+}
+
+func setRegisters(device *i2c.FakeDevice, reg byte, values ...byte) {
+	for i, value := range values {
+		device.SetRegister(reg+byte(i), value)
+	}
+}
+
+func TestLSM6DS3Init(t *testing.T) {
+	device := new(i2c.FakeDevice).Init(DefaultLSM6DS3Address)
+
+	// Bits of CTRL4_C other than the bandwidth selection must survive the init
+	device.SetRegister(lsm6ds3Ctrl4C, 0x04)
+
+	if _, err := new(LSM6DS3).Init(device, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expectTransactions(t, device, []i2c.Transaction{
+		{Write: true, Data: []byte{lsm6ds3Ctrl1XL, 0x42}},
+		{Write: true, Data: []byte{lsm6ds3Ctrl4C}},
+		{Write: false, Data: []byte{0x04}},
+		{Write: true, Data: []byte{lsm6ds3Ctrl4C, 0x84}},
+		{Write: true, Data: []byte{lsm6ds3Ctrl2G, 0x4C}},
+	})
+}
+
+func TestLSM6DS3InitRequiresAddress(t *testing.T) {
+	if _, err := new(LSM6DS3).Init(new(i2c.FakeDevice).Init(0), nil); err == nil {
+		t.Fatal("expected an error for a device without an address")
+	}
+}
+
+func TestLSM6DS3ReadAccelerationData(t *testing.T) {
+	lsm6ds3, device := newFakeLSM6DS3(t)
+
+	// 61 micro g per digit truncated to whole g, 16394 is just over 1g and 32767 just under 2g
+	setRegisters(device, lsm6ds3OutLXL, 0x0A, 0x40, 0xF6, 0xBF, 0xFF, 0x7F)
+
+	data, err := lsm6ds3.ReadAccelerationData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data != (LSM6DS3Data{X: 1, Y: -1, Z: 1}) {
+		t.Fatalf("acceleration is %+v", data)
+	}
+
+	expectTransactions(t, device, []i2c.Transaction{
+		{Write: true, Data: []byte{lsm6ds3OutLXL}},
+		{Write: false, Data: []byte{0x0A, 0x40, 0xF6, 0xBF, 0xFF, 0x7F}},
+	})
+}
+
+func TestLSM6DS3ReadGyroData(t *testing.T) {
+	lsm6ds3, device := newFakeLSM6DS3(t)
+
+	// 70 milli degrees per second per digit at 2000 dps
+	setRegisters(device, lsm6ds3OutXLG, 0x64, 0x00, 0x9C, 0xFF, 0x00, 0x00)
+
+	data, err := lsm6ds3.ReadGyroData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data != (LSM6DS3Data{X: 7, Y: -7, Z: 0}) {
+		t.Fatalf("gyro is %+v", data)
+	}
+
+	expectTransactions(t, device, []i2c.Transaction{
+		{Write: true, Data: []byte{lsm6ds3OutXLG}},
+		{Write: false, Data: []byte{0x64, 0x00, 0x9C, 0xFF, 0x00, 0x00}},
+	})
+}
+
+func TestLSM6DS3ReadData(t *testing.T) {
+	lsm6ds3, device := newFakeLSM6DS3(t)
+
+	if _, _, _, err := lsm6ds3.ReadData(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Acceleration, gyro and temperature are each read with a register write and a read
+	transactions := device.Transactions()
+
+	if len(transactions) != 6 {
+		t.Fatalf("expected 6 transactions, got %d", len(transactions))
+	}
+
+	for i, reg := range []byte{lsm6ds3OutLXL, lsm6ds3OutXLG, lsm6ds3OutTempL} {
+		if transactions[2*i].Data[0] != reg {
+			t.Fatalf("read %d started at register 0x%x, expected 0x%x", i, transactions[2*i].Data[0], reg)
+		}
+	}
+}
+
+func TestLSM6DS3ReadFailsOnClosedDevice(t *testing.T) {
+	lsm6ds3, device := newFakeLSM6DS3(t)
+	_ = device.Close()
+
+	if _, _, _, err := lsm6ds3.ReadData(); err == nil {
+		t.Fatal("expected an error for a closed device")
+	}
+}
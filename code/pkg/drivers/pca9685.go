@@ -2,17 +2,40 @@ package drivers
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	i2c "github.com/r4stl1n/micro-hal/code/pkg/drivers/base"
 )
 
-const DefaultPCA9685Address = 0x40
+const (
+	DefaultPCA9685Address = 0x40
+	PCA9685ChannelCount   = 16
+)
+
+// Default register addresses, Mode1 is the only register at address zero so these are
+// also used when the options leave them at zero
+const (
+	pca9685Mode1    = 0x00
+	pca9685PreScale = 0xFE
+	pca9685Led0On   = 0x06
+	pca9685AllLedOn = 0xFA
+)
 
 // PCA9685 is a Driver for the PCA9685 16-channel 12-bit PWM/Servo controller
 type PCA9685 struct {
-	i2c     *i2c.I2C
+	i2c     i2c.Device
 	options *PCA9685Options
+
+	// Channel writes are serialized so the cached counts always match the chip
+	mutex    sync.Mutex
+	channels [PCA9685ChannelCount]PCA9685Channel
+}
+
+// PCA9685Channel is the on and off count of a single PWM channel
+type PCA9685Channel struct {
+	On  int
+	Off int
 }
 
 // PCA9685Options for controller
@@ -25,10 +48,11 @@ type PCA9685Options struct {
 	Mode1    byte
 	PreScale byte
 	Led0On   byte
+	AllLedOn byte
 }
 
 // Init creates the new PCA9685 driver with specified i2c interface and options
-func (pca9685 *PCA9685) Init(i2c *i2c.I2C, options *PCA9685Options) (*PCA9685, error) {
+func (pca9685 *PCA9685) Init(i2c i2c.Device, options *PCA9685Options) (*PCA9685, error) {
 
 	adr := i2c.GetAddr()
 
@@ -44,14 +68,29 @@ func (pca9685 *PCA9685) Init(i2c *i2c.I2C, options *PCA9685Options) (*PCA9685, e
 			Frequency:  50.0,       // 50Hz
 			ClockSpeed: 25000000.0, // 25MHz
 
-			Mode1:    0x00, // Default Mode1
-			PreScale: 0xFE, // Default PreScale
-			Led0On:   0x06, // default Led0On
+			Mode1:    pca9685Mode1,
+			PreScale: pca9685PreScale,
+			Led0On:   pca9685Led0On,
+			AllLedOn: pca9685AllLedOn,
 		},
 	}
 
 	if options != nil {
-		pca9685.options = options
+		opts := *options
+
+		if opts.PreScale == 0 {
+			opts.PreScale = pca9685PreScale
+		}
+
+		if opts.Led0On == 0 {
+			opts.Led0On = pca9685Led0On
+		}
+
+		if opts.AllLedOn == 0 {
+			opts.AllLedOn = pca9685AllLedOn
+		}
+
+		pca9685.options = &opts
 	}
 
 	if err := pca9685.i2c.WriteRegU8(pca9685.options.Mode1, 0x00|0xA1); err != nil { // Mode 1, autoincrement on)
@@ -62,9 +101,31 @@ func (pca9685 *PCA9685) Init(i2c *i2c.I2C, options *PCA9685Options) (*PCA9685, e
 		return nil, err
 	}
 
+	if err := pca9685.readChannels(); err != nil {
+		return nil, err
+	}
+
 	return pca9685, nil
 }
 
+// readChannels fills the channel cache with the counts currently set on the chip
+func (pca9685 *PCA9685) readChannels() error {
+	buf, _, err := pca9685.i2c.ReadRegBytes(pca9685.options.Led0On, 4*PCA9685ChannelCount)
+
+	if err != nil {
+		return err
+	}
+
+	for chn := range pca9685.channels {
+		pca9685.channels[chn] = PCA9685Channel{
+			On:  int(buf[4*chn]) | int(buf[(4*chn)+1])<<8,
+			Off: int(buf[(4*chn)+2]) | int(buf[(4*chn)+3])<<8,
+		}
+	}
+
+	return nil
+}
+
 func (pca9685 *PCA9685) Name() string {
 	return pca9685.options.Name
 }
@@ -115,24 +176,130 @@ func (pca9685 *PCA9685) GetOptions() *PCA9685Options {
 	return pca9685.options
 }
 
+// Channel returns the counts last written to a PWM channel
+func (pca9685 *PCA9685) Channel(chn int) PCA9685Channel {
+	pca9685.mutex.Lock()
+	defer pca9685.mutex.Unlock()
+
+	if chn < 0 || chn >= PCA9685ChannelCount {
+		return PCA9685Channel{}
+	}
+
+	return pca9685.channels[chn]
+}
+
 // SetChannel sets a single PWM channel
 func (pca9685 *PCA9685) SetChannel(chn, on, off int) error {
+	return pca9685.SetChannelBlock(chn, []PCA9685Channel{{On: on, Off: off}})
+}
+
+// SetChannelBlock sets consecutive PWM channels starting at first in a single write, the
+// chip auto increments through the LEDn registers
+func (pca9685 *PCA9685) SetChannelBlock(first int, values []PCA9685Channel) error {
 
-	if chn < 0 || chn > 15 {
+	if first < 0 || first+len(values) > PCA9685ChannelCount {
 		return fmt.Errorf("invalid [channel] value")
 	}
 
-	if on < 0 || on > int(pca9685.options.StepCount) {
+	pca9685.mutex.Lock()
+	defer pca9685.mutex.Unlock()
+
+	return pca9685.writeBlock(first, values)
+}
+
+// SetChannels sets every PWM channel in the map in a single write. The block runs from
+// the lowest to the highest channel, the channels in between are written with their
+// current counts
+func (pca9685 *PCA9685) SetChannels(values map[int]PCA9685Channel) error {
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	first, last := PCA9685ChannelCount, -1
+
+	for chn := range values {
+		if chn < 0 || chn >= PCA9685ChannelCount {
+			return fmt.Errorf("invalid [channel] value")
+		}
+
+		if chn < first {
+			first = chn
+		}
+
+		if chn > last {
+			last = chn
+		}
+	}
+
+	pca9685.mutex.Lock()
+	defer pca9685.mutex.Unlock()
+
+	block := append([]PCA9685Channel{}, pca9685.channels[first:last+1]...)
+
+	for chn, value := range values {
+		block[chn-first] = value
+	}
+
+	return pca9685.writeBlock(first, block)
+}
+
+// SetAllChannels sets every PWM channel to the same counts with a single write to the
+// ALL_LED registers
+func (pca9685 *PCA9685) SetAllChannels(on, off int) error {
+	value := PCA9685Channel{On: on, Off: off}
+
+	if err := pca9685.validate(value); err != nil {
+		return err
+	}
+
+	buf := []byte{pca9685.options.AllLedOn, byte(on) & 0xFF, byte(on >> 8), byte(off) & 0xFF, byte(off >> 8)}
+
+	pca9685.mutex.Lock()
+	defer pca9685.mutex.Unlock()
+
+	if _, err := pca9685.i2c.WriteBytes(buf); err != nil {
+		return err
+	}
+
+	for chn := range pca9685.channels {
+		pca9685.channels[chn] = value
+	}
+
+	return nil
+}
+
+func (pca9685 *PCA9685) validate(value PCA9685Channel) error {
+	if value.On < 0 || value.On > int(pca9685.options.StepCount) {
 		return fmt.Errorf("invalid [on] value")
 	}
 
-	if off < 0 || off > int(pca9685.options.StepCount) {
+	if value.Off < 0 || value.Off > int(pca9685.options.StepCount) {
 		return fmt.Errorf("invalid [off] value")
 	}
 
-	buf := []byte{pca9685.options.Led0On + byte(4*chn), byte(on) & 0xFF, byte(on >> 8), byte(off) & 0xFF, byte(off >> 8)}
+	return nil
+}
 
-	_, err := pca9685.i2c.WriteBytes(buf)
+// writeBlock writes the channels starting at first and updates the cache, it must be
+// called with the mutex held
+func (pca9685 *PCA9685) writeBlock(first int, values []PCA9685Channel) error {
+	buf := make([]byte, 0, 1+(4*len(values)))
+	buf = append(buf, pca9685.options.Led0On+byte(4*first))
 
-	return err
+	for _, value := range values {
+		if err := pca9685.validate(value); err != nil {
+			return err
+		}
+
+		buf = append(buf, byte(value.On)&0xFF, byte(value.On>>8), byte(value.Off)&0xFF, byte(value.Off>>8))
+	}
+
+	if _, err := pca9685.i2c.WriteBytes(buf); err != nil {
+		return err
+	}
+
+	copy(pca9685.channels[first:], values)
+
+	return nil
 }
@@ -0,0 +1,230 @@
+package drivers
+
+import (
+	"bytes"
+	"testing"
+
+	i2c "github.com/r4stl1n/micro-hal/code/pkg/drivers/base"
+)
+
+func newFakePCA9685(t *testing.T) (*PCA9685, *i2c.FakeDevice) {
+	device := new(i2c.FakeDevice).Init(DefaultPCA9685Address)
+
+	pca, err := new(PCA9685).Init(device, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device.ClearTransactions()
+
+	return pca, device
+}
+
+func expectTransactions(t *testing.T, device *i2c.FakeDevice, expected []i2c.Transaction) {
+	t.Helper()
+
+	transactions := device.Transactions()
+
+	if len(transactions) != len(expected) {
+		t.Fatalf("expected %d transactions, got %d: %+v", len(expected), len(transactions), transactions)
+	}
+
+	for i := range expected {
+		if transactions[i].Write != expected[i].Write || !bytes.Equal(transactions[i].Data, expected[i].Data) {
+			t.Fatalf("transaction %d is %+v, expected %+v", i, transactions[i], expected[i])
+		}
+	}
+}
+
+func TestPCA9685Init(t *testing.T) {
+	device := new(i2c.FakeDevice).Init(DefaultPCA9685Address)
+
+	if _, err := new(PCA9685).Init(device, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 25MHz / 4096 steps / 50Hz rounds to a prescale of 122
+	expectTransactions(t, device, []i2c.Transaction{
+		{Write: true, Data: []byte{0x00, 0xA1}},
+		{Write: true, Data: []byte{0x00}},
+		{Write: false, Data: []byte{0xA1}},
+		{Write: true, Data: []byte{0x00, 0x31}},
+		{Write: true, Data: []byte{0xFE, 122}},
+		{Write: true, Data: []byte{0x00, 0xA1}},
+		{Write: true, Data: []byte{0x06}},
+		{Write: false, Data: make([]byte, 4*PCA9685ChannelCount)},
+	})
+
+	if device.Register(0xFE) != 122 {
+		t.Fatalf("prescale register is %d", device.Register(0xFE))
+	}
+}
+
+func TestPCA9685InitRequiresAddress(t *testing.T) {
+	if _, err := new(PCA9685).Init(new(i2c.FakeDevice).Init(0), nil); err == nil {
+		t.Fatal("expected an error for a device without an address")
+	}
+}
+
+func TestPCA9685InitFailsOnClosedDevice(t *testing.T) {
+	device := new(i2c.FakeDevice).Init(DefaultPCA9685Address)
+	_ = device.Close()
+
+	if _, err := new(PCA9685).Init(device, nil); err == nil {
+		t.Fatal("expected an error for a closed device")
+	}
+}
+
+func TestPCA9685SetFreqRejectsHighFrequency(t *testing.T) {
+	pca, device := newFakePCA9685(t)
+
+	if err := pca.SetFreq(5000); err == nil {
+		t.Fatal("expected an error for a prescale below 3")
+	}
+
+	expectTransactions(t, device, nil)
+}
+
+func TestPCA9685SetChannel(t *testing.T) {
+	pca, device := newFakePCA9685(t)
+
+	if err := pca.SetChannel(3, 0x123, 0x456); err != nil {
+		t.Fatal(err)
+	}
+
+	// LED3_ON_L is at 0x06 + 4*3, on and off are written low byte first
+	expectTransactions(t, device, []i2c.Transaction{
+		{Write: true, Data: []byte{0x12, 0x23, 0x01, 0x56, 0x04}},
+	})
+
+	if channel := pca.Channel(3); channel.On != 0x123 || channel.Off != 0x456 {
+		t.Fatalf("channel 3 is %+v", channel)
+	}
+}
+
+func TestPCA9685SetChannelRejectsInvalidValues(t *testing.T) {
+	pca, device := newFakePCA9685(t)
+
+	for _, values := range [][3]int{{-1, 0, 0}, {PCA9685ChannelCount, 0, 0}, {0, -1, 0}, {0, 0, 4097}, {0, 4097, 0}} {
+		if err := pca.SetChannel(values[0], values[1], values[2]); err == nil {
+			t.Fatalf("expected an error for channel %d on %d off %d", values[0], values[1], values[2])
+		}
+	}
+
+	expectTransactions(t, device, nil)
+}
+
+func TestPCA9685Reset(t *testing.T) {
+	pca, device := newFakePCA9685(t)
+
+	if err := pca.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectTransactions(t, device, []i2c.Transaction{{Write: true, Data: []byte{0x00, 0x00}}})
+}
+
+func TestPCA9685InitDefaultsZeroRegisters(t *testing.T) {
+	device := new(i2c.FakeDevice).Init(DefaultPCA9685Address)
+
+	options := &PCA9685Options{StepCount: 4096.0, Frequency: 50.0, ClockSpeed: 25000000.0}
+
+	pca, err := new(PCA9685).Init(device, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := pca.GetOptions(); got.PreScale != 0xFE || got.Led0On != 0x06 || got.AllLedOn != 0xFA {
+		t.Fatalf("zero registers were not defaulted: %+v", got)
+	}
+
+	if options.AllLedOn != 0 {
+		t.Fatal("the options passed to Init were modified")
+	}
+
+	device.ClearTransactions()
+
+	if err := pca.SetAllChannels(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	expectTransactions(t, device, []i2c.Transaction{{Write: true, Data: []byte{0xFA, 0x00, 0x00, 0x00, 0x00}}})
+}
+
+func TestPCA9685SetChannels(t *testing.T) {
+	device := new(i2c.FakeDevice).Init(DefaultPCA9685Address)
+
+	// Channel 4 is already running, it lies between the written channels and must be kept
+	device.SetRegister(0x06+(4*4)+2, 0x34)
+	device.SetRegister(0x06+(4*4)+3, 0x01)
+
+	pca, err := new(PCA9685).Init(device, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device.ClearTransactions()
+
+	err = pca.SetChannels(map[int]PCA9685Channel{
+		5: {On: 0, Off: 0x200},
+		3: {On: 0x10, Off: 0x150},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A single auto increment write from LED3_ON_L covering channels 3 to 5
+	expectTransactions(t, device, []i2c.Transaction{{Write: true, Data: []byte{
+		0x12,
+		0x10, 0x00, 0x50, 0x01,
+		0x00, 0x00, 0x34, 0x01,
+		0x00, 0x00, 0x00, 0x02,
+	}}})
+
+	for chn, expected := range map[int]PCA9685Channel{3: {0x10, 0x150}, 4: {0, 0x134}, 5: {0, 0x200}} {
+		if channel := pca.Channel(chn); channel != expected {
+			t.Fatalf("channel %d is %+v, expected %+v", chn, channel, expected)
+		}
+	}
+}
+
+func TestPCA9685SetChannelsRejectsInvalidValues(t *testing.T) {
+	pca, device := newFakePCA9685(t)
+
+	for _, values := range []map[int]PCA9685Channel{
+		{-1: {0, 0}},
+		{PCA9685ChannelCount: {0, 0}},
+		{0: {0, 100}, 1: {0, 4097}},
+	} {
+		if err := pca.SetChannels(values); err == nil {
+			t.Fatalf("expected an error for %+v", values)
+		}
+	}
+
+	if err := pca.SetChannels(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expectTransactions(t, device, nil)
+}
+
+func TestPCA9685SetAllChannels(t *testing.T) {
+	pca, device := newFakePCA9685(t)
+
+	if err := pca.SetAllChannels(0x100, 0x2AB); err != nil {
+		t.Fatal(err)
+	}
+
+	expectTransactions(t, device, []i2c.Transaction{{Write: true, Data: []byte{0xFA, 0x00, 0x01, 0xAB, 0x02}}})
+
+	for chn := 0; chn < PCA9685ChannelCount; chn++ {
+		if channel := pca.Channel(chn); channel != (PCA9685Channel{On: 0x100, Off: 0x2AB}) {
+			t.Fatalf("channel %d is %+v after setting all channels", chn, channel)
+		}
+	}
+
+	if err := pca.SetAllChannels(0, 5000); err == nil {
+		t.Fatal("expected an error for an off count past 4096")
+	}
+}
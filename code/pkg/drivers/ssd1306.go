@@ -151,7 +151,7 @@ func (ssd1306Init *SSD1306Init) GetSequence(screenFlipped bool) []byte {
 
 // SSD1306 is a Driver for the PCA9685 16-channel 12-bit PWM/Servo controller
 type SSD1306 struct {
-	i2c           *i2c.I2C
+	i2c           i2c.Device
 	initSequence  *SSD1306Init
 	options       *SSD1306Options
 	displayBuffer []byte
@@ -166,7 +166,7 @@ type SSD1306Options struct {
 }
 
 // Init creates the new PCA9685 driver with specified i2c interface and options
-func (ssd1306 *SSD1306) Init(i2c *i2c.I2C, options *SSD1306Options) (*SSD1306, error) {
+func (ssd1306 *SSD1306) Init(i2c i2c.Device, options *SSD1306Options) (*SSD1306, error) {
 
 	adr := i2c.GetAddr()
 
@@ -0,0 +1,154 @@
+package drivers
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	i2c "github.com/r4stl1n/micro-hal/code/pkg/drivers/base"
+)
+
+func newFakeSSD1306(t *testing.T, options *SSD1306Options) (*SSD1306, *i2c.FakeDevice) {
+	device := new(i2c.FakeDevice).Init(DefaultSSD1306Address)
+
+	ssd1306, err := new(SSD1306).Init(device, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device.ClearTransactions()
+
+	return ssd1306, device
+}
+
+// commandBytes prefixes every command with the 0x80 control byte used by the driver
+func commandBytes(commands ...byte) []byte {
+	data := []byte{}
+
+	for _, command := range commands {
+		data = append(data, 0x80, command)
+	}
+
+	return data
+}
+
+func TestSSD1306Init(t *testing.T) {
+	device := new(i2c.FakeDevice).Init(DefaultSSD1306Address)
+
+	if _, err := new(SSD1306).Init(device, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expectTransactions(t, device, []i2c.Transaction{
+		{Write: true, Data: commandBytes(ssd1306SetDisplayOff)},
+		{Write: true, Data: commandBytes(new(SSD1306Init).Init128X64().GetSequence(false)...)},
+		{Write: true, Data: commandBytes(ssd1306ColumnAddr, 0, 127)},
+		{Write: true, Data: commandBytes(ssd1306PageAddr, 0, 7)},
+		{Write: true, Data: commandBytes(ssd1306SetDisplayOn)},
+	})
+}
+
+func TestSSD1306InitSequence(t *testing.T) {
+	device := new(i2c.FakeDevice).Init(DefaultSSD1306Address)
+
+	options := &SSD1306Options{Width: 128, Height: 32, PageSize: 8, ExternalVCC: true}
+
+	if _, err := new(SSD1306).Init(device, options); err != nil {
+		t.Fatal(err)
+	}
+
+	sequence := device.Transactions()[1].Data
+
+	expected := map[byte]byte{
+		ssd1306SetMultiplexRatio:  0x1F,
+		ssd1306SetComPins:         0x02,
+		ssd1306ChargePumpSetting:  0x10,
+		ssd1306SetContrast:        0x9F,
+		ssd1306SetPreChargePeriod: 0x22,
+	}
+
+	// Commands with an argument are followed by the argument in the next control pair
+	for i := 1; i+2 < len(sequence); i += 2 {
+		if value, ok := expected[sequence[i]]; ok {
+			if sequence[i+2] != value {
+				t.Fatalf("command 0x%x has argument 0x%x, expected 0x%x", sequence[i], sequence[i+2], value)
+			}
+
+			delete(expected, sequence[i])
+		}
+	}
+
+	if len(expected) != 0 {
+		t.Fatalf("commands %v missing from the init sequence", expected)
+	}
+}
+
+func TestSSD1306InitRejectsResolution(t *testing.T) {
+	device := new(i2c.FakeDevice).Init(DefaultSSD1306Address)
+
+	if _, err := new(SSD1306).Init(device, &SSD1306Options{Width: 64, Height: 48, PageSize: 8}); err == nil {
+		t.Fatal("expected an error for an unsupported resolution")
+	}
+
+	expectTransactions(t, device, nil)
+}
+
+func TestSSD1306SetContrast(t *testing.T) {
+	ssd1306, device := newFakeSSD1306(t, nil)
+
+	if err := ssd1306.SetContrast(0x42); err != nil {
+		t.Fatal(err)
+	}
+
+	expectTransactions(t, device, []i2c.Transaction{{Write: true, Data: commandBytes(ssd1306SetContrast, 0x42)}})
+}
+
+func TestSSD1306Display(t *testing.T) {
+	ssd1306, device := newFakeSSD1306(t, &SSD1306Options{Width: 96, Height: 16, PageSize: 8})
+
+	ssd1306.Clear()
+	ssd1306.SetPixel(0, 0, 1)
+	ssd1306.SetPixel(1, 7, 1)
+	ssd1306.SetPixel(95, 15, 1)
+	ssd1306.SetPixel(1, 7, 0)
+	ssd1306.SetPixel(1, 6, 1)
+
+	if err := ssd1306.Display(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := make([]byte, 1+ssd1306.BufferSize())
+	expected[0] = 0x40
+	expected[1+0] = 0x01
+	expected[1+1] = 0x40
+	expected[1+96+95] = 0x80
+
+	expectTransactions(t, device, []i2c.Transaction{{Write: true, Data: expected}})
+}
+
+func TestSSD1306ShowImage(t *testing.T) {
+	ssd1306, device := newFakeSSD1306(t, &SSD1306Options{Width: 96, Height: 16, PageSize: 8})
+
+	if err := ssd1306.ShowImage(image.NewGray(image.Rect(0, 0, 128, 64))); err == nil {
+		t.Fatal("expected an error for an image that does not match the display")
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 96, 16))
+	img.Set(2, 9, color.White)
+
+	if err := ssd1306.ShowImage(img); err != nil {
+		t.Fatal(err)
+	}
+
+	transactions := device.Transactions()
+
+	if len(transactions) != 1 {
+		t.Fatalf("expected a single display write, got %d", len(transactions))
+	}
+
+	for i, value := range transactions[0].Data[1:] {
+		if (i == 96+2 && value != 0x02) || (i != 96+2 && value != 0) {
+			t.Fatalf("display byte %d is 0x%x", i, value)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package messages
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// EStop engages the emergency stop on every node receiving it
+type EStop struct {
+	Reason string
+}
+
+func (eStop *EStop) Init(reason string) *EStop {
+	*eStop = EStop{
+		Reason: reason,
+	}
+
+	return eStop
+}
+
+func (eStop *EStop) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&eStop)
+	return bytes
+}
+
+func (eStop *EStop) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &eStop)
+}
+
+// EStopReset releases a latched emergency stop, the token must match the reset token
+// configured on the node
+type EStopReset struct {
+	Token string
+}
+
+func (eStopReset *EStopReset) Init(token string) *EStopReset {
+	*eStopReset = EStopReset{
+		Token: token,
+	}
+
+	return eStopReset
+}
+
+func (eStopReset *EStopReset) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&eStopReset)
+	return bytes
+}
+
+func (eStopReset *EStopReset) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &eStopReset)
+}
+
+// EStopStatus describes the emergency stop state of a node
+type EStopStatus struct {
+	Node    string
+	Engaged bool
+	Reason  string
+	Source  string // sender of the message that engaged the emergency stop
+	Since   int64  // unix nanoseconds at which the emergency stop was engaged
+}
+
+func (eStopStatus *EStopStatus) Init() *EStopStatus {
+	*eStopStatus = EStopStatus{}
+	return eStopStatus
+}
+
+func (eStopStatus *EStopStatus) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&eStopStatus)
+	return bytes
+}
+
+func (eStopStatus *EStopStatus) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &eStopStatus)
+}
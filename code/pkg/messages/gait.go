@@ -0,0 +1,23 @@
+package messages
+
+import "github.com/vmihailenco/msgpack/v5"
+
+type Gait struct {
+	Name string
+}
+
+func (gait *Gait) Init(name string) *Gait {
+	*gait = Gait{
+		Name: name,
+	}
+	return gait
+}
+
+func (gait *Gait) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&gait)
+	return bytes
+}
+
+func (gait *Gait) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &gait)
+}
@@ -0,0 +1,37 @@
+package messages
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// ServoState is the last command written to a single servo
+type ServoState struct {
+	Alias      string
+	PinId      int
+	JointAngle float32 // commanded joint angle in radians
+	Angle      float32 // servo angle in degrees written to the servo
+	Ticks      int     // pca9685 off count last written to the channel
+	Resolution float32 // degrees moved by a single tick
+	Clamped    bool    // clamped into the actuation range
+	Limited    bool    // held back by a soft limit or joint constraint
+}
+
+// JointState is the last commanded joint positions together with the state of every servo
+type JointState struct {
+	Joints Joints
+	Servos []ServoState
+}
+
+func (jointState *JointState) Init() *JointState {
+	*jointState = JointState{
+		Servos: []ServoState{},
+	}
+	return jointState
+}
+
+func (jointState *JointState) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&jointState)
+	return bytes
+}
+
+func (jointState *JointState) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &jointState)
+}
@@ -1,25 +1,54 @@
 package messages
 
 import (
-	"github.com/sirupsen/logrus"
+	"time"
+
 	"github.com/vmihailenco/msgpack/v5"
 )
 
+// clockBase anchors message timestamps to the wall clock once, after that they advance
+// with the monotonic clock so they never run backwards within a process
+var clockBase = time.Now()
+
+// Now returns the current message timestamp in unix nanoseconds
+func Now() int64 {
+	return clockBase.UnixNano() + int64(time.Since(clockBase))
+}
+
 type MessageType int
 
 const (
 	ExampleRequestMessage  MessageType = 1
 	ExampleResponseMessage MessageType = 2
 
-	JointsMessage MessageType = 3
-	PoseMessage   MessageType = 4
+	JointsMessage       MessageType = 3
+	PoseMessage         MessageType = 4
+	VelocitiesMessage   MessageType = 5
+	GaitMessage         MessageType = 6
+	ResultMessage       MessageType = 7
+	QueryMessage        MessageType = 8
+	JointStateMessage   MessageType = 9
+	WatchdogTripMessage MessageType = 10
+	EStopMessage        MessageType = 11
+	EStopResetMessage   MessageType = 12
+	EStopStatusMessage  MessageType = 13
+	RobotStateMessage   MessageType = 14
+	StateRequestMessage MessageType = 15
 )
 
+// SchemaVersion is the version of the message envelope written by Stamp
+const SchemaVersion = 1
+
 type Message struct {
 	Type     MessageType
 	Data     []byte
 	RespChan string
 	Multi    bool
+
+	Version   int    // envelope schema version, zero for unstamped messages
+	Timestamp int64  // send time in unix nanoseconds, monotonic within the sender
+	Sequence  uint64 // per sender and channel sequence number starting at one
+	Sender    string // name of the sending node
 }
 
 func (message *Message) Request(respChan string) *Message {
@@ -45,6 +74,26 @@ func (message *Message) ResponseMulti(multi bool) *Message {
 	return message
 }
 
+// Stamp sets the envelope metadata of the message before it is sent
+func (message *Message) Stamp(sender string, sequence uint64) *Message {
+	message.Version = SchemaVersion
+	message.Timestamp = Now()
+	message.Sequence = sequence
+	message.Sender = sender
+
+	return message
+}
+
+// Age returns how long ago the message was sent, zero for unstamped messages. It is the
+// local time minus the timestamp of the sender so it is only accurate with synced clocks
+func (message *Message) Age() time.Duration {
+	if message.Timestamp == 0 {
+		return 0
+	}
+
+	return time.Duration(Now() - message.Timestamp)
+}
+
 func (message *Message) Pack() []byte {
 	bytes, _ := msgpack.Marshal(&message)
 	return bytes
@@ -54,37 +103,55 @@ func (message *Message) Unpack(data []byte) error {
 	return msgpack.Unmarshal(data, &message)
 }
 
-func (message *Message) packMessage(response interface{}) {
-	switch response.(type) {
-
-	case *ExampleRequest:
-		message.Type = ExampleRequestMessage
-		message.Data = response.(*ExampleRequest).Pack()
-	case *ExampleResponse:
-		message.Type = ExampleResponseMessage
-		message.Data = response.(*ExampleResponse).Pack()
-	case *Joints:
-		message.Type = JointsMessage
-		message.Data = response.(*Joints).Pack()
-	case *Pose:
-		message.Type = PoseMessage
-		message.Data = response.(*Pose).Pack()
-
-	default:
-		logrus.Errorf("Unknown message type %v+", response)
+// Payload is implemented by every message that can be carried in the Data of a Message
+type Payload interface {
+	Pack() []byte
+	Unpack(data []byte) error
+}
+
+func (message *Message) packMessage(response interface{}) error {
+	messageType, err := TypeOf(response)
+	if err != nil {
+		return err
 	}
+
+	message.Type = messageType
+	message.Data = response.(Payload).Pack()
+
+	return nil
 }
 
-func (message *Message) Build(response interface{}) []byte {
+// Build wraps the payload in the message and packs it, the payload type must be registered
+func (message *Message) Build(response interface{}) ([]byte, error) {
 
-	message.packMessage(response)
+	if err := message.packMessage(response); err != nil {
+		return nil, err
+	}
 
-	return message.Pack()
+	return message.Pack(), nil
 }
 
-func (message *Message) BuildM(response interface{}) *Message {
+// BuildM wraps the payload in the message, the payload type must be registered
+func (message *Message) BuildM(response interface{}) (*Message, error) {
 
-	message.packMessage(response)
+	if err := message.packMessage(response); err != nil {
+		return nil, err
+	}
 
-	return message
+	return message, nil
+}
+
+// Decode unpacks the data of the message into a payload of the type registered for
+// the message type
+func (message *Message) Decode() (Payload, error) {
+	payload, err := New(message.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := payload.Unpack(message.Data); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
 }
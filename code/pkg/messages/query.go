@@ -0,0 +1,21 @@
+package messages
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// Query is an empty request sent to the get channels
+type Query struct {
+}
+
+func (query *Query) Init() *Query {
+	*query = Query{}
+	return query
+}
+
+func (query *Query) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&query)
+	return bytes
+}
+
+func (query *Query) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &query)
+}
@@ -0,0 +1,90 @@
+package messages
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var registry = struct {
+	mutex     sync.RWMutex
+	types     map[reflect.Type]MessageType
+	factories map[MessageType]func() Payload
+}{
+	types:     map[reflect.Type]MessageType{},
+	factories: map[MessageType]func() Payload{},
+}
+
+func init() {
+	MustRegister(ExampleRequestMessage, func() Payload { return new(ExampleRequest) })
+	MustRegister(ExampleResponseMessage, func() Payload { return new(ExampleResponse) })
+	MustRegister(JointsMessage, func() Payload { return new(Joints) })
+	MustRegister(PoseMessage, func() Payload { return new(Pose) })
+	MustRegister(VelocitiesMessage, func() Payload { return new(Velocities) })
+	MustRegister(GaitMessage, func() Payload { return new(Gait) })
+	MustRegister(ResultMessage, func() Payload { return new(Result) })
+	MustRegister(QueryMessage, func() Payload { return new(Query) })
+	MustRegister(JointStateMessage, func() Payload { return new(JointState) })
+	MustRegister(WatchdogTripMessage, func() Payload { return new(WatchdogTrip) })
+	MustRegister(EStopMessage, func() Payload { return new(EStop) })
+	MustRegister(EStopResetMessage, func() Payload { return new(EStopReset) })
+	MustRegister(EStopStatusMessage, func() Payload { return new(EStopStatus) })
+	MustRegister(RobotStateMessage, func() Payload { return new(RobotState) })
+	MustRegister(StateRequestMessage, func() Payload { return new(StateRequest) })
+}
+
+// Register maps the payload type created by the factory to the message type. Both the
+// message type and the payload type can only be registered once
+func Register(messageType MessageType, factory func() Payload) error {
+	payloadType := reflect.TypeOf(factory())
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if _, ok := registry.factories[messageType]; ok {
+		return fmt.Errorf("message type %d is already registered", messageType)
+	}
+
+	if existing, ok := registry.types[payloadType]; ok {
+		return fmt.Errorf("%s is already registered as message type %d", payloadType, existing)
+	}
+
+	registry.types[payloadType] = messageType
+	registry.factories[messageType] = factory
+
+	return nil
+}
+
+// MustRegister is like Register but panics when the registration fails, it is meant
+// to be called from init functions
+func MustRegister(messageType MessageType, factory func() Payload) {
+	if err := Register(messageType, factory); err != nil {
+		panic(err)
+	}
+}
+
+// TypeOf returns the message type registered for the payload
+func TypeOf(payload interface{}) (MessageType, error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	messageType, ok := registry.types[reflect.TypeOf(payload)]
+	if !ok {
+		return 0, fmt.Errorf("unknown message type %T", payload)
+	}
+
+	return messageType, nil
+}
+
+// New returns an empty payload of the concrete type registered for the message type
+func New(messageType MessageType) (Payload, error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	factory, ok := registry.factories[messageType]
+	if !ok {
+		return nil, fmt.Errorf("unknown message type %d", messageType)
+	}
+
+	return factory(), nil
+}
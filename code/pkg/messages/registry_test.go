@@ -0,0 +1,133 @@
+package messages
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// testPayload is only registered by the tests
+type testPayload struct {
+	Value int
+}
+
+func (payload *testPayload) Pack() []byte {
+	bytes, _ := msgpack.Marshal(payload)
+	return bytes
+}
+
+func (payload *testPayload) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, payload)
+}
+
+// otherTestPayload is never registered
+type otherTestPayload struct {
+	testPayload
+}
+
+func TestRegistryRoundTrip(t *testing.T) {
+	tests := []struct {
+		messageType MessageType
+		payload     Payload
+	}{
+		{JointsMessage, &Joints{LeftFront: hmath.Vec3{0.1, 0.2, 0.3}}},
+		{PoseMessage, &Pose{X: 0.01, Pitch: 0.2}},
+		{VelocitiesMessage, &Velocities{LinearX: 0.3, AngularZ: -0.5}},
+		{GaitMessage, new(Gait).Init("trot")},
+		{ResultMessage, new(Result).Init(FailureResult, "refused")},
+		{QueryMessage, new(Query).Init()},
+		{JointStateMessage, &JointState{Servos: []ServoState{{Alias: "front-left-leg", Angle: 60}}}},
+		{EStopMessage, new(EStop).Init("test")},
+		{RobotStateMessage, &RobotState{State: "standing", Progress: 1}},
+		{StateRequestMessage, new(StateRequest).Init("sitting")},
+	}
+
+	for _, test := range tests {
+		messageType, err := TypeOf(test.payload)
+		if err != nil || messageType != test.messageType {
+			t.Errorf("%T registered as %d, expected %d: %v", test.payload, messageType, test.messageType, err)
+			continue
+		}
+
+		data, err := new(Message).Request("").Build(test.payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		message := new(Message)
+		if err := message.Unpack(data); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := message.Decode()
+		if err != nil {
+			t.Fatalf("%T: %s", test.payload, err)
+		}
+
+		if message.Type != test.messageType || !reflect.DeepEqual(decoded, test.payload) {
+			t.Errorf("decoded %T %+v as type %d, expected %+v", decoded, decoded, message.Type, test.payload)
+		}
+	}
+}
+
+func TestRegisterRefusesDuplicates(t *testing.T) {
+	const testMessage MessageType = 1000
+
+	if err := Register(testMessage, func() Payload { return new(testPayload) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if messageType, err := TypeOf(&testPayload{Value: 1}); err != nil || messageType != testMessage {
+		t.Fatalf("registered payload has type %d: %v", messageType, err)
+	}
+
+	if payload, err := New(testMessage); err != nil || reflect.TypeOf(payload) != reflect.TypeOf(&testPayload{}) {
+		t.Fatalf("registered type created %T: %v", payload, err)
+	}
+
+	if err := Register(testMessage, func() Payload { return new(otherTestPayload) }); err == nil {
+		t.Error("message type registered twice")
+	}
+
+	if err := Register(testMessage+1, func() Payload { return new(testPayload) }); err == nil {
+		t.Error("payload type registered twice")
+	}
+
+	if _, err := New(testMessage + 1); err == nil {
+		t.Error("refused registration was added to the registry")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustRegister did not panic on a duplicate")
+		}
+	}()
+
+	MustRegister(PoseMessage, func() Payload { return new(otherTestPayload) })
+}
+
+func TestRegistryUnknownTypes(t *testing.T) {
+	if _, err := New(MessageType(999)); err == nil {
+		t.Error("unknown message type created a payload")
+	}
+
+	if _, err := TypeOf(new(otherTestPayload)); err == nil {
+		t.Error("unregistered payload has a message type")
+	}
+
+	// Only pointers to the payloads are registered
+	if _, err := TypeOf(Pose{}); err == nil {
+		t.Error("pose value has a message type")
+	}
+
+	if _, err := new(Message).Request("").BuildM(new(otherTestPayload)); err == nil {
+		t.Error("unregistered payload was built into a message")
+	}
+
+	message := &Message{Type: MessageType(999), Data: new(Query).Init().Pack()}
+	if _, err := message.Decode(); err == nil {
+		t.Error("message of an unknown type was decoded")
+	}
+}
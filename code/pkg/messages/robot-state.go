@@ -0,0 +1,46 @@
+package messages
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// RobotState describes the mode of the controller
+type RobotState struct {
+	State    string
+	Target   string  // state the controller is moving towards
+	Progress float32 // progress of the running transition between 0 and 1
+}
+
+func (robotState *RobotState) Init() *RobotState {
+	*robotState = RobotState{}
+	return robotState
+}
+
+func (robotState *RobotState) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&robotState)
+	return bytes
+}
+
+func (robotState *RobotState) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &robotState)
+}
+
+// StateRequest asks the controller to move to the target state
+type StateRequest struct {
+	Target string
+}
+
+func (stateRequest *StateRequest) Init(target string) *StateRequest {
+	*stateRequest = StateRequest{
+		Target: target,
+	}
+
+	return stateRequest
+}
+
+func (stateRequest *StateRequest) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&stateRequest)
+	return bytes
+}
+
+func (stateRequest *StateRequest) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &stateRequest)
+}
@@ -0,0 +1,23 @@
+package messages
+
+import "github.com/vmihailenco/msgpack/v5"
+
+type Velocities struct {
+	LinearX  float32
+	LinearY  float32
+	AngularZ float32
+}
+
+func (velocities *Velocities) Init() *Velocities {
+	*velocities = Velocities{}
+	return velocities
+}
+
+func (velocities *Velocities) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&velocities)
+	return bytes
+}
+
+func (velocities *Velocities) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &velocities)
+}
@@ -0,0 +1,28 @@
+package messages
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// WatchdogTrip is published when the controller stops walking because the velocity
+// commands went stale
+type WatchdogTrip struct {
+	Timeout float32 // configured command timeout in seconds
+	Since   float32 // seconds since the last velocity command
+
+	LinearX  float32 // velocities that were being executed when the watchdog tripped
+	LinearY  float32
+	AngularZ float32
+}
+
+func (watchdogTrip *WatchdogTrip) Init() *WatchdogTrip {
+	*watchdogTrip = WatchdogTrip{}
+	return watchdogTrip
+}
+
+func (watchdogTrip *WatchdogTrip) Pack() []byte {
+	bytes, _ := msgpack.Marshal(&watchdogTrip)
+	return bytes
+}
+
+func (watchdogTrip *WatchdogTrip) Unpack(data []byte) error {
+	return msgpack.Unmarshal(data, &watchdogTrip)
+}
@@ -0,0 +1,98 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrStaleCommand      = errors.New("stale command")
+	ErrOutOfOrderCommand = errors.New("out of order command")
+)
+
+// CommandFilterStats counts the commands seen by a CommandFilter
+type CommandFilterStats struct {
+	Accepted   uint64
+	Stale      uint64
+	OutOfOrder uint64
+	Missed     uint64 // commands skipped in the sequence of a sender
+}
+
+type commandStream struct {
+	sequence  uint64
+	timestamp int64
+}
+
+// CommandFilter drops control commands that are older than the max age or arrive out of
+// order and reports gaps in the sequence of every sender and channel. Messages without
+// envelope metadata are always accepted. The age compares the clock of the sender with
+// the local clock so the max age needs synced clocks, a zero max age disables the check
+type CommandFilter struct {
+	maxAge time.Duration
+
+	mutex   sync.Mutex
+	streams map[string]commandStream
+	stats   CommandFilterStats
+}
+
+func (commandFilter *CommandFilter) Init(maxAge time.Duration) *CommandFilter {
+	*commandFilter = CommandFilter{
+		maxAge:  maxAge,
+		streams: map[string]commandStream{},
+	}
+
+	return commandFilter
+}
+
+// Stats returns the counters of the filter
+func (commandFilter *CommandFilter) Stats() CommandFilterStats {
+	commandFilter.mutex.Lock()
+	defer commandFilter.mutex.Unlock()
+
+	return commandFilter.stats
+}
+
+// Accept returns an error wrapping ErrStaleCommand or ErrOutOfOrderCommand when the
+// message received on the channel should be dropped
+func (commandFilter *CommandFilter) Accept(channel string, message *messages.Message) error {
+	if message.Version == 0 {
+		return nil
+	}
+
+	commandFilter.mutex.Lock()
+	defer commandFilter.mutex.Unlock()
+
+	if age := message.Age(); commandFilter.maxAge > 0 && age > commandFilter.maxAge {
+		commandFilter.stats.Stale = commandFilter.stats.Stale + 1
+		return fmt.Errorf("%w from %s on %s, %s old", ErrStaleCommand, message.Sender, channel, age)
+	}
+
+	key := message.Sender + " " + channel
+	last, seen := commandFilter.streams[key]
+
+	if seen && message.Sequence <= last.sequence {
+		// A lower sequence with a newer timestamp means the sender restarted
+		if message.Timestamp <= last.timestamp {
+			commandFilter.stats.OutOfOrder = commandFilter.stats.OutOfOrder + 1
+			return fmt.Errorf("%w from %s on %s, sequence %d after %d", ErrOutOfOrderCommand, message.Sender,
+				channel, message.Sequence, last.sequence)
+		}
+
+		logrus.Infof("sequence of %s on %s restarted at %d", message.Sender, channel, message.Sequence)
+	} else if seen && message.Sequence > last.sequence+1 {
+		missed := message.Sequence - last.sequence - 1
+
+		commandFilter.stats.Missed = commandFilter.stats.Missed + missed
+		logrus.Warnf("missed %d commands from %s on %s", missed, message.Sender, channel)
+	}
+
+	commandFilter.streams[key] = commandStream{sequence: message.Sequence, timestamp: message.Timestamp}
+	commandFilter.stats.Accepted = commandFilter.stats.Accepted + 1
+
+	return nil
+}
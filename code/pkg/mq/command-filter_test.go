@@ -0,0 +1,92 @@
+package mq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+func stampedCommand(sender string, sequence uint64, timestamp int64) *messages.Message {
+	message := new(messages.Message).Request("").Stamp(sender, sequence)
+	message.Timestamp = timestamp
+
+	return message
+}
+
+func TestCommandFilterSequences(t *testing.T) {
+	base := messages.Now()
+	at := func(offset int) int64 { return base + int64(offset)*int64(time.Millisecond) }
+
+	tests := []struct {
+		name     string
+		channel  string
+		message  *messages.Message
+		expected error
+	}{
+		{"unstamped", "cmd", new(messages.Message).Request(""), nil},
+		{"first", "cmd", stampedCommand("a", 1, at(1)), nil},
+		{"next", "cmd", stampedCommand("a", 2, at(2)), nil},
+		{"duplicate", "cmd", stampedCommand("a", 2, at(2)), ErrOutOfOrderCommand},
+		{"reordered", "cmd", stampedCommand("a", 1, at(1)), ErrOutOfOrderCommand},
+		{"gap", "cmd", stampedCommand("a", 5, at(5)), nil},
+		{"other sender", "cmd", stampedCommand("b", 1, at(3)), nil},
+		{"other channel", "other", stampedCommand("a", 1, at(3)), nil},
+		{"restarted sender", "cmd", stampedCommand("a", 1, at(10)), nil},
+		{"after restart", "cmd", stampedCommand("a", 2, at(11)), nil},
+		{"gap with an older timestamp", "cmd", stampedCommand("a", 4, at(4)), nil},
+		{"repeated with an older timestamp", "cmd", stampedCommand("b", 1, at(2)), ErrOutOfOrderCommand},
+	}
+
+	commandFilter := new(CommandFilter).Init(0)
+
+	for _, test := range tests {
+		if err := commandFilter.Accept(test.channel, test.message); !errors.Is(err, test.expected) ||
+			(test.expected == nil && err != nil) {
+			t.Errorf("%s: got %v, expected %v", test.name, err, test.expected)
+		}
+	}
+
+	// The unstamped message is accepted without being counted. Only the sequence orders the
+	// commands of a sender, so sequence 4 after 2 is a gap of one on top of the first gap of two
+	expected := CommandFilterStats{Accepted: 8, OutOfOrder: 3, Missed: 3}
+
+	if stats := commandFilter.Stats(); stats != expected {
+		t.Fatalf("filter stats %+v, expected %+v", stats, expected)
+	}
+}
+
+func TestCommandFilterMaxAge(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxAge   time.Duration
+		age      time.Duration
+		expected error
+	}{
+		{"disabled", 0, time.Hour, nil},
+		{"fresh", 100 * time.Millisecond, 0, nil},
+		{"stale", 100 * time.Millisecond, time.Second, ErrStaleCommand},
+	}
+
+	for _, test := range tests {
+		commandFilter := new(CommandFilter).Init(test.maxAge)
+		message := stampedCommand("a", 1, messages.Now()-int64(test.age))
+
+		err := commandFilter.Accept("cmd", message)
+		if !errors.Is(err, test.expected) || (test.expected == nil && err != nil) {
+			t.Errorf("%s: got %v, expected %v", test.name, err, test.expected)
+		}
+
+		// A stale command does not advance the sequence of the sender
+		if test.expected != nil {
+			if err := commandFilter.Accept("cmd", stampedCommand("a", 1, messages.Now())); err != nil {
+				t.Errorf("%s: fresh command after a stale one refused: %s", test.name, err)
+			}
+
+			if stats := commandFilter.Stats(); stats.Stale != 1 || stats.Accepted != 1 {
+				t.Errorf("%s: filter stats %+v", test.name, stats)
+			}
+		}
+	}
+}
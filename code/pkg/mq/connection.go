@@ -0,0 +1,103 @@
+package mq
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrDisconnected     = errors.New("not connected to nats")
+	ErrConnectionClosed = errors.New("nats connection closed")
+)
+
+type ConnectionState int
+
+const (
+	StateConnected    ConnectionState = 1
+	StateDisconnected ConnectionState = 2
+	StateReconnected  ConnectionState = 3
+	StateClosed       ConnectionState = 4
+)
+
+func (connectionState ConnectionState) String() string {
+	switch connectionState {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateReconnected:
+		return "reconnected"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectionEvent is sent to the connection handlers every time the state of the
+// connection changes
+type ConnectionEvent struct {
+	State ConnectionState
+	Time  time.Time
+	Err   error // cause of a disconnect when known
+}
+
+// OnConnectionEvent registers a handler that is called for every connection state change.
+// Handlers are called one at a time in the order the events happen
+func (n *Nats) OnConnectionEvent(handler func(ConnectionEvent)) {
+	n.handlerMutex.Lock()
+	defer n.handlerMutex.Unlock()
+
+	n.connectionHandlers = append(n.connectionHandlers, handler)
+}
+
+// Connected returns true while the connection to the server is up
+func (n *Nats) Connected() bool {
+	return n.Conn != nil && n.Conn.IsConnected()
+}
+
+// Closed returns true once the connection has been closed and will not reconnect
+func (n *Nats) Closed() bool {
+	return n.Conn != nil && n.Conn.IsClosed()
+}
+
+func (n *Nats) emit(event ConnectionEvent) {
+	n.handlerMutex.Lock()
+	handlers := append([]func(ConnectionEvent){}, n.connectionHandlers...)
+	n.handlerMutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+func (n *Nats) connectionEventOptions() []nats.Option {
+	return []nats.Option{
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			logrus.Warnf("disconnected from nats: %v", err)
+			n.emit(ConnectionEvent{State: StateDisconnected, Time: time.Now(), Err: err})
+		}),
+
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logrus.Infof("reconnected to nats server %s", conn.ConnectedServerId())
+			n.emit(ConnectionEvent{State: StateReconnected, Time: time.Now()})
+		}),
+
+		nats.ClosedHandler(func(_ *nats.Conn) {
+			logrus.Warn("nats connection closed")
+			n.emit(ConnectionEvent{State: StateClosed, Time: time.Now()})
+		}),
+
+		nats.ErrorHandler(func(_ *nats.Conn, subscription *nats.Subscription, err error) {
+			if subscription != nil {
+				logrus.Errorf("nats error on %s: %s", subscription.Subject, err)
+				return
+			}
+
+			logrus.Errorf("nats error: %s", err)
+		}),
+	}
+}
@@ -0,0 +1,188 @@
+package mq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+// restartableServer is an embedded server that can be stopped and started again on the
+// same address to make the clients reconnect
+type restartableServer struct {
+	t      *testing.T
+	server *Server
+	addr   string
+}
+
+func startRestartableServer(t *testing.T) *restartableServer {
+	restartable := &restartableServer{t: t, server: new(Server).Init(structs.NatsConfig{Host: "127.0.0.1:-1"})}
+
+	if err := restartable.server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	restartable.addr = restartable.server.Addr()
+
+	t.Cleanup(func() {
+		restartable.server.Shutdown()
+	})
+
+	return restartable
+}
+
+func (restartable *restartableServer) stop() {
+	restartable.server.Shutdown()
+}
+
+func (restartable *restartableServer) restart() {
+	restartable.server = new(Server).Init(structs.NatsConfig{Host: restartable.addr})
+
+	if err := restartable.server.Start(); err != nil {
+		restartable.t.Fatal(err)
+	}
+}
+
+// connectWithEvents connects to the server and returns the connection events it reports
+func connectWithEvents(t *testing.T, natsConfig structs.NatsConfig) (*Nats, chan ConnectionEvent) {
+	events := make(chan ConnectionEvent, 16)
+
+	connection := new(Nats).Init(natsConfig)
+	connection.OnConnectionEvent(func(event ConnectionEvent) {
+		events <- event
+	})
+
+	if err := connection.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(connection.Close)
+
+	return connection, events
+}
+
+func expectEvent(t *testing.T, events chan ConnectionEvent, state ConnectionState) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case event := <-events:
+			if event.State == state {
+				return
+			}
+
+		case <-timeout:
+			t.Fatalf("connection did not report %s", state)
+		}
+	}
+}
+
+func expectPublished(t *testing.T, received chan *nats.Msg, text string) {
+	t.Helper()
+
+	select {
+	case msg := <-received:
+		message := new(messages.Message)
+		result := new(messages.Result)
+
+		if err := message.Unpack(msg.Data); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := result.Unpack(message.Data); err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Text != text {
+			t.Fatalf("received %s, expected %s", result.Text, text)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s was not received", text)
+	}
+}
+
+func TestConnectionReconnects(t *testing.T) {
+	server := startRestartableServer(t)
+
+	tests := []struct {
+		name            string
+		reconnectBuffer int
+	}{
+		{"unbuffered", 0},
+		{"buffered", 1024 * 1024},
+	}
+
+	for _, test := range tests {
+		natsConfig := structs.NatsConfig{Host: server.addr, Name: "test", MaxReconnects: -1,
+			ReconnectBuffer: test.reconnectBuffer}
+
+		connection, events := connectWithEvents(t, natsConfig)
+		expectEvent(t, events, StateConnected)
+
+		received := make(chan *nats.Msg, 16)
+
+		if _, err := connection.Conn.ChanSubscribe("test.reconnect", received); err != nil {
+			t.Fatal(err)
+		}
+
+		server.stop()
+		expectEvent(t, events, StateDisconnected)
+
+		if connection.Connected() || connection.Closed() {
+			t.Fatalf("%s: connection reports connected %t and closed %t while reconnecting", test.name,
+				connection.Connected(), connection.Closed())
+		}
+
+		err := connection.Publish("test.reconnect", textResult("while disconnected"))
+
+		switch {
+		case test.reconnectBuffer == 0 && !errors.Is(err, ErrDisconnected):
+			t.Fatalf("%s: publish while disconnected returned %v, expected %v", test.name, err, ErrDisconnected)
+
+		case test.reconnectBuffer > 0 && err != nil:
+			t.Fatalf("%s: buffered publish while disconnected failed: %s", test.name, err)
+		}
+
+		server.restart()
+		expectEvent(t, events, StateReconnected)
+
+		// The subscription is restored on the reconnect, a buffered publish is replayed first
+		if test.reconnectBuffer > 0 {
+			expectPublished(t, received, "while disconnected")
+		}
+
+		if err := connection.Publish("test.reconnect", textResult("after reconnect")); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		expectPublished(t, received, "after reconnect")
+
+		connection.Close()
+		expectEvent(t, events, StateClosed)
+	}
+}
+
+func TestConnectionClosesWithoutReconnects(t *testing.T) {
+	server := startRestartableServer(t)
+
+	connection, events := connectWithEvents(t, structs.NatsConfig{Host: server.addr, Name: "test"})
+
+	server.stop()
+
+	expectEvent(t, events, StateDisconnected)
+	expectEvent(t, events, StateClosed)
+
+	if !connection.Closed() {
+		t.Fatal("connection without reconnects is not closed after losing the server")
+	}
+
+	if err := connection.Publish("test.reconnect", textResult("after close")); !errors.Is(err, ErrDisconnected) {
+		t.Fatalf("publish on a closed connection returned %v, expected %v", err, ErrDisconnected)
+	}
+}
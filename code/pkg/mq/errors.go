@@ -0,0 +1,47 @@
+package mq
+
+import (
+	"fmt"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+// TimeoutError is returned when a request is cancelled or its deadline expires before
+// a response is received. It unwraps to the error of the context
+type TimeoutError struct {
+	Channel string
+	Err     error
+}
+
+func (timeoutError *TimeoutError) Error() string {
+	return fmt.Sprintf("did not receive response on %s: %v", timeoutError.Channel, timeoutError.Err)
+}
+
+func (timeoutError *TimeoutError) Unwrap() error {
+	return timeoutError.Err
+}
+
+// DecodeError is returned when a response can not be unpacked into the expected type
+type DecodeError struct {
+	Channel string
+	Type    messages.MessageType
+	Err     error
+}
+
+func (decodeError *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode response of type %d on %s: %v", decodeError.Type, decodeError.Channel, decodeError.Err)
+}
+
+func (decodeError *DecodeError) Unwrap() error {
+	return decodeError.Err
+}
+
+// RemoteError is returned when the responder replies with a failure result
+type RemoteError struct {
+	Channel string
+	Result  messages.Result
+}
+
+func (remoteError *RemoteError) Error() string {
+	return fmt.Sprintf("request on %s failed: %s", remoteError.Channel, remoteError.Result.Text)
+}
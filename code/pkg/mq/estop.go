@@ -0,0 +1,203 @@
+package mq
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrEStopEngaged     = errors.New("emergency stop engaged")
+	ErrEStopResetDenied = errors.New("emergency stop reset denied")
+)
+
+// EStopLatch holds the emergency stop state of a node. Once engaged it stays engaged
+// until it is released with the reset token configured on the node, an empty reset
+// token refuses every reset so the node has to be restarted
+type EStopLatch struct {
+	nats       *Nats
+	resetToken string
+
+	onEngage  func(status *messages.EStopStatus)
+	onRelease func()
+
+	mutex  sync.Mutex
+	status messages.EStopStatus
+}
+
+// Init creates the latch, onEngage is called after the latch engages and onRelease after
+// it is released. Either callback can be nil
+func (eStopLatch *EStopLatch) Init(nats *Nats, resetToken string, onEngage func(status *messages.EStopStatus),
+	onRelease func()) *EStopLatch {
+
+	*eStopLatch = EStopLatch{
+		nats:       nats,
+		resetToken: resetToken,
+		onEngage:   onEngage,
+		onRelease:  onRelease,
+		status:     messages.EStopStatus{Node: nats.Config.Name},
+	}
+
+	if resetToken == "" {
+		logrus.Warn("no emergency stop reset token configured, an emergency stop will require a restart")
+	}
+
+	return eStopLatch
+}
+
+// Engaged returns true while the emergency stop is engaged
+func (eStopLatch *EStopLatch) Engaged() bool {
+	eStopLatch.mutex.Lock()
+	defer eStopLatch.mutex.Unlock()
+
+	return eStopLatch.status.Engaged
+}
+
+// Check returns an error wrapping ErrEStopEngaged while the emergency stop is engaged
+func (eStopLatch *EStopLatch) Check() error {
+	eStopLatch.mutex.Lock()
+	defer eStopLatch.mutex.Unlock()
+
+	if eStopLatch.status.Engaged {
+		return fmt.Errorf("%w: %s", ErrEStopEngaged, eStopLatch.status.Reason)
+	}
+
+	return nil
+}
+
+// Status returns the current emergency stop state
+func (eStopLatch *EStopLatch) Status() *messages.EStopStatus {
+	eStopLatch.mutex.Lock()
+	defer eStopLatch.mutex.Unlock()
+
+	status := eStopLatch.status
+
+	return &status
+}
+
+// Engage latches the emergency stop, it returns false when it was already engaged
+func (eStopLatch *EStopLatch) Engage(reason string, source string) bool {
+	eStopLatch.mutex.Lock()
+
+	if eStopLatch.status.Engaged {
+		eStopLatch.mutex.Unlock()
+		return false
+	}
+
+	eStopLatch.status.Engaged = true
+	eStopLatch.status.Reason = reason
+	eStopLatch.status.Source = source
+	eStopLatch.status.Since = time.Now().UnixNano()
+
+	status := eStopLatch.status
+	eStopLatch.mutex.Unlock()
+
+	logrus.Errorf("emergency stop engaged by %s: %s", source, reason)
+
+	if eStopLatch.onEngage != nil {
+		eStopLatch.onEngage(&status)
+	}
+
+	eStopLatch.publish(&status)
+
+	return true
+}
+
+// Release clears the emergency stop when the token matches the configured reset token
+func (eStopLatch *EStopLatch) Release(token string) error {
+	if eStopLatch.resetToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(eStopLatch.resetToken)) != 1 {
+		return ErrEStopResetDenied
+	}
+
+	eStopLatch.mutex.Lock()
+
+	if !eStopLatch.status.Engaged {
+		eStopLatch.mutex.Unlock()
+		return nil
+	}
+
+	eStopLatch.status = messages.EStopStatus{Node: eStopLatch.status.Node}
+
+	status := eStopLatch.status
+	eStopLatch.mutex.Unlock()
+
+	logrus.Warn("emergency stop released")
+
+	if eStopLatch.onRelease != nil {
+		eStopLatch.onRelease()
+	}
+
+	eStopLatch.publish(&status)
+
+	return nil
+}
+
+// Register handles the emergency stop messages on the service and subscribes it to the
+// emergency stop channels. The service should not handle other commands so an emergency
+// stop is never queued behind them
+func (eStopLatch *EStopLatch) Register(service *Service) error {
+	service.Handle(messages.EStopMessage, eStopLatch.handleEStop)
+	service.Handle(messages.EStopResetMessage, eStopLatch.handleReset)
+	service.Handle(messages.QueryMessage, eStopLatch.handleGet)
+
+	for _, channel := range []string{consts.MQEStopChannel, consts.MQEStopResetChannel, consts.MQEStopGetChannel} {
+		subscribeError := service.Subscribe(channel)
+		if subscribeError != nil {
+			return subscribeError
+		}
+	}
+
+	return nil
+}
+
+func (eStopLatch *EStopLatch) handleEStop(request *Request, _ *Responder) (interface{}, error) {
+	eStop := new(messages.EStop)
+
+	unpackError := request.Unpack(eStop)
+	if unpackError != nil {
+		// An emergency stop that can not be read is still an emergency stop
+		eStop.Reason = "unreadable emergency stop message"
+	}
+
+	source := request.Message.Sender
+	if source == "" {
+		source = "unknown"
+	}
+
+	eStopLatch.Engage(eStop.Reason, source)
+
+	return eStopLatch.Status(), nil
+}
+
+func (eStopLatch *EStopLatch) handleReset(request *Request, _ *Responder) (interface{}, error) {
+	eStopReset := new(messages.EStopReset)
+
+	unpackError := request.Unpack(eStopReset)
+	if unpackError != nil {
+		return nil, unpackError
+	}
+
+	releaseError := eStopLatch.Release(eStopReset.Token)
+	if releaseError != nil {
+		return nil, fmt.Errorf("%w for %s", releaseError, request.Message.Sender)
+	}
+
+	return eStopLatch.Status(), nil
+}
+
+func (eStopLatch *EStopLatch) handleGet(_ *Request, _ *Responder) (interface{}, error) {
+	return eStopLatch.Status(), nil
+}
+
+func (eStopLatch *EStopLatch) publish(status *messages.EStopStatus) {
+	publishError := eStopLatch.nats.Publish(consts.MQEStopStateChannel, status)
+	if publishError != nil && !errors.Is(publishError, ErrDisconnected) {
+		logrus.Error(publishError)
+	}
+}
@@ -0,0 +1,189 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+// eStopFixture runs a latch on its own service and counts the callbacks
+type eStopFixture struct {
+	latch     *EStopLatch
+	client    *Nats
+	published chan *nats.Msg
+	engaged   chan *messages.EStopStatus
+	released  chan struct{}
+}
+
+func startEStopLatch(t *testing.T, resetToken string) *eStopFixture {
+	natsConfig := startServer(t)
+
+	fixture := &eStopFixture{
+		client:    connect(t, natsConfig, "client"),
+		published: make(chan *nats.Msg, 16),
+		engaged:   make(chan *messages.EStopStatus, 16),
+		released:  make(chan struct{}, 16),
+	}
+
+	if _, err := fixture.client.Conn.ChanSubscribe(consts.MQEStopStateChannel, fixture.published); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fixture.client.Conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	node := connect(t, natsConfig, "node")
+
+	fixture.latch = new(EStopLatch).Init(node, resetToken,
+		func(status *messages.EStopStatus) { fixture.engaged <- status },
+		func() { fixture.released <- struct{}{} })
+
+	service := new(Service).Init(node, 1)
+
+	if err := fixture.latch.Register(service); err != nil {
+		t.Fatal(err)
+	}
+
+	runService(t, service)
+
+	return fixture
+}
+
+func (fixture *eStopFixture) request(channel string, payload interface{}) (*messages.EStopStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	status := new(messages.EStopStatus)
+
+	if err := fixture.client.Request(ctx, channel, payload, status); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// expectPublishedStatus waits for the latch to publish its state
+func (fixture *eStopFixture) expectPublishedStatus(t *testing.T, engaged bool) {
+	t.Helper()
+
+	select {
+	case msg := <-fixture.published:
+		message := new(messages.Message)
+		status := new(messages.EStopStatus)
+
+		if err := message.Unpack(msg.Data); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := status.Unpack(message.Data); err != nil {
+			t.Fatal(err)
+		}
+
+		if status.Node != "node" || status.Engaged != engaged {
+			t.Fatalf("latch published %+v, expected engaged %t", status, engaged)
+		}
+
+	case <-time.After(2 * time.Second):
+		t.Fatalf("latch did not publish engaged %t", engaged)
+	}
+}
+
+func TestEStopLatchEngagesAndResets(t *testing.T) {
+	fixture := startEStopLatch(t, "reset")
+
+	if err := fixture.latch.Check(); err != nil {
+		t.Fatalf("released latch refuses commands: %s", err)
+	}
+
+	status, err := fixture.request(consts.MQEStopChannel, new(messages.EStop).Init("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !status.Engaged || status.Node != "node" || status.Reason != "test" || status.Source != "client" || status.Since == 0 {
+		t.Fatalf("emergency stop replied %+v", status)
+	}
+
+	fixture.expectPublishedStatus(t, true)
+
+	select {
+	case engaged := <-fixture.engaged:
+		if *engaged != *status {
+			t.Fatalf("engage callback got %+v, expected %+v", engaged, status)
+		}
+	default:
+		t.Fatal("engage callback was not called")
+	}
+
+	// The latch refuses commands until it is reset
+	if err := fixture.latch.Check(); !errors.Is(err, ErrEStopEngaged) {
+		t.Fatalf("engaged latch returned %v, expected %v", err, ErrEStopEngaged)
+	}
+
+	// A second emergency stop keeps the first reason and does not call back again
+	if fixture.latch.Engage("again", "test") {
+		t.Fatal("engaged latch engaged again")
+	}
+
+	if len(fixture.engaged) != 0 || fixture.latch.Status().Reason != "test" {
+		t.Fatalf("second emergency stop changed the latch to %+v", fixture.latch.Status())
+	}
+
+	_, err = fixture.request(consts.MQEStopResetChannel, new(messages.EStopReset).Init("wrong"))
+	expectRemoteError(t, err, ErrEStopResetDenied.Error()+" for client")
+
+	status, err = fixture.request(consts.MQEStopGetChannel, new(messages.Query).Init())
+	if err != nil || !status.Engaged {
+		t.Fatalf("status after a denied reset is %+v: %v", status, err)
+	}
+
+	status, err = fixture.request(consts.MQEStopResetChannel, new(messages.EStopReset).Init("reset"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *status != (messages.EStopStatus{Node: "node"}) {
+		t.Fatalf("reset replied %+v", status)
+	}
+
+	fixture.expectPublishedStatus(t, false)
+
+	select {
+	case <-fixture.released:
+	default:
+		t.Fatal("release callback was not called")
+	}
+
+	if err := fixture.latch.Check(); err != nil {
+		t.Fatalf("reset latch refuses commands: %s", err)
+	}
+
+	// Resetting a released latch changes nothing
+	if err := fixture.latch.Release("reset"); err != nil || len(fixture.released) != 0 {
+		t.Fatalf("reset of a released latch returned %v", err)
+	}
+}
+
+func TestEStopLatchWithoutResetToken(t *testing.T) {
+	fixture := startEStopLatch(t, "")
+
+	if !fixture.latch.Engage("test", "test") {
+		t.Fatal("latch did not engage")
+	}
+
+	for _, token := range []string{"", "reset"} {
+		if err := fixture.latch.Release(token); !errors.Is(err, ErrEStopResetDenied) {
+			t.Errorf("reset with %q returned %v, expected %v", token, err, ErrEStopResetDenied)
+		}
+	}
+
+	if !fixture.latch.Engaged() {
+		t.Fatal("latch without a reset token was released")
+	}
+}
@@ -1,26 +1,39 @@
 package mq
 
 import (
+	"context"
 	"fmt"
 	"github.com/nats-io/nats.go"
-	"github.com/r4stl1n/micro-hal/code/pkg/consts"
 	"github.com/r4stl1n/micro-hal/code/pkg/messages"
 	"github.com/r4stl1n/micro-hal/code/pkg/structs"
-	"github.com/satori/go.uuid"
 	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
 	"time"
 )
 
+const defaultRequestTimeout = 5 * time.Second
+
 type Nats struct {
 	Config      structs.NatsConfig
 	Conn        *nats.Conn
 	EncodedConn *nats.EncodedConn
 	Error       error
+
+	inboxMutex sync.Mutex
+	inbox      *responseInbox
+
+	sequenceMutex sync.Mutex
+	sequences     map[string]uint64
+
+	handlerMutex       sync.Mutex
+	connectionHandlers []func(ConnectionEvent)
 }
 
 func (n *Nats) Init(cfg structs.NatsConfig) *Nats {
 	*n = Nats{
-		Config: cfg,
+		Config:    cfg,
+		sequences: map[string]uint64{},
 	}
 
 	return n
@@ -31,144 +44,178 @@ func (n *Nats) Connect() error {
 		return n.Error
 	}
 
-	descriptor := fmt.Sprintf("mq://%s:%s@%s", n.Config.User, n.Config.Pass, n.Config.Host)
+	options, optionsError := n.connectOptions()
+	if optionsError != nil {
+		n.Error = optionsError
+		return n.Error
+	}
+
+	logrus.Infof("Connecting to nats, %s", n.Config)
 
-	n.Conn, n.Error = nats.Connect(descriptor, nats.ReconnectWait(time.Second))
+	n.Conn, n.Error = nats.Connect(strings.Join(n.Config.ServerList(), ","), options...)
 	if n.Error != nil {
 		return n.Error
 	}
 
 	n.EncodedConn, n.Error = nats.NewEncodedConn(n.Conn, nats.DEFAULT_ENCODER)
+	if n.Error != nil {
+		return n.Error
+	}
 
-	return n.Error
-}
+	n.emit(ConnectionEvent{State: StateConnected, Time: time.Now()})
 
-func (n *Nats) SendAwaitResponse(channel string, message *messages.Message) (*messages.Message, error) {
-	newResponseChannel := consts.MQNodePrefix + uuid.NewV4().String()
+	return nil
+}
 
-	receiveChannel := make(chan *[]byte, 100)
-	subscription, bindError := n.EncodedConn.BindRecvChan(newResponseChannel, receiveChannel)
-	if bindError != nil {
-		return nil, bindError
+// Close closes the connection, requests waiting for a response fail and no more messages
+// are received
+func (n *Nats) Close() {
+	if n.Conn != nil {
+		n.Conn.Close()
 	}
+}
 
-	defer func() {
-		unsubscribeError := subscription.Unsubscribe()
+// connectOptions builds the connection options from the config. Credentials are only
+// passed as options so they never end up in a server url
+func (n *Nats) connectOptions() ([]nats.Option, error) {
+	options := []nats.Option{
+		nats.ReconnectWait(time.Second),
+		nats.MaxReconnects(n.Config.MaxReconnects),
+		nats.DontRandomize(),
+	}
 
-		if unsubscribeError != nil {
-			logrus.Error(unsubscribeError)
-		}
-	}()
+	// Publishes made while disconnected fail instead of being replayed late after a
+	// reconnect unless a buffer has been configured
+	if n.Config.ReconnectBuffer > 0 {
+		options = append(options, nats.ReconnectBufSize(n.Config.ReconnectBuffer))
+	} else {
+		options = append(options, nats.ReconnectBufSize(-1))
+	}
 
-	message.RespChan = newResponseChannel
+	options = append(options, n.connectionEventOptions()...)
 
-	publishError := n.EncodedConn.Publish(channel, message.Pack())
-	if publishError != nil {
-		return nil, publishError
+	if n.Config.Name != "" {
+		options = append(options, nats.Name(n.Config.Name))
 	}
 
-	select {
-	case receiveData := <-receiveChannel:
-		unpackMessage := new(messages.Message)
-		unpackError := unpackMessage.Unpack(*receiveData)
+	switch {
+	case n.Config.CredsFile != "":
+		options = append(options, nats.UserCredentials(n.Config.CredsFile))
 
-		if unpackError != nil {
-			return nil, unpackError
+	case n.Config.NKeySeedFile != "":
+		nkeyOption, err := nats.NkeyOptionFromSeed(n.Config.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load nkey seed: %w", err)
 		}
 
-		return unpackMessage, nil
+		options = append(options, nkeyOption)
 
-	case <-time.After(5 * time.Second):
-		return nil, fmt.Errorf("did not receive response for requested message")
-	}
-}
+	case n.Config.Token != "":
+		options = append(options, nats.Token(n.Config.Token))
 
-func (n *Nats) SendAwaitResponseMultiSingle(channel string, message *messages.Message) ([]*messages.Message, error) {
-	newResponseChannel := consts.MQNodePrefix + uuid.NewV4().String()
+	case n.Config.User != "":
+		options = append(options, nats.UserInfo(n.Config.User, n.Config.Pass))
+	}
 
-	receiveChannel := make(chan *[]byte, 100)
-	subscription, bindError := n.EncodedConn.BindRecvChan(newResponseChannel, receiveChannel)
-	if bindError != nil {
-		return nil, bindError
+	if n.Config.TLSCert != "" || n.Config.TLSKey != "" {
+		options = append(options, nats.ClientCert(n.Config.TLSCert, n.Config.TLSKey))
 	}
 
-	defer func() {
-		unsubscribeError := subscription.Unsubscribe()
-		if unsubscribeError != nil {
-			logrus.Error(unsubscribeError)
-		}
-	}()
+	if n.Config.TLSCA != "" {
+		options = append(options, nats.RootCAs(n.Config.TLSCA))
+	}
 
-	message.RespChan = newResponseChannel
+	return options, nil
+}
 
-	publishError := n.EncodedConn.Publish(channel, message.Pack())
-	if publishError != nil {
-		return nil, publishError
+// Publish wraps the payload in a message and publishes it on the channel
+func (n *Nats) Publish(channel string, payload interface{}) error {
+	message, err := new(messages.Message).BuildM(payload)
+	if err != nil {
+		return err
 	}
 
-	t := time.NewTimer(5 * time.Second)
-	results := make([]*messages.Message, 0)
+	return n.PublishMessage(channel, message)
+}
+
+// PublishMessage stamps the message with the envelope metadata of this connection and
+// publishes it on the channel
+func (n *Nats) PublishMessage(channel string, message *messages.Message) error {
+	n.sequenceMutex.Lock()
+	n.sequences[channel] = n.sequences[channel] + 1
+	sequence := n.sequences[channel]
+	n.sequenceMutex.Unlock()
 
-	for {
-		select {
-		case receiveData := <-receiveChannel:
-			unpackMessage := new(messages.Message)
-			unpackError := unpackMessage.Unpack(*receiveData)
-			if unpackError != nil {
-				return nil, unpackError
-			}
+	return n.publishStamped(channel, message, sequence)
+}
 
-			results = append(results, unpackMessage)
-			if !unpackMessage.Multi {
-				return results, nil
-			}
+func (n *Nats) publishStamped(channel string, message *messages.Message, sequence uint64) error {
+	if n.EncodedConn == nil {
+		return ErrDisconnected
+	}
 
-			t.Reset(5 * time.Second)
+	err := n.EncodedConn.Publish(channel, message.Stamp(n.Config.Name, sequence).Pack())
 
-		case <-t.C:
-			return nil, fmt.Errorf("did not receive response for requested message")
-		}
+	if err != nil && !n.Connected() {
+		return fmt.Errorf("%w: failed to publish on %s: %s", ErrDisconnected, channel, err)
 	}
+
+	return err
 }
 
-func (n *Nats) SendAwaitResponseMulti(channel string, message *messages.Message) ([]*messages.Message, error) {
-	newResponseChannel := consts.MQNodePrefix + uuid.NewV4().String()
+// SendAwaitResponse sends the message and waits up to five seconds for a single response
+func (n *Nats) SendAwaitResponse(channel string, message *messages.Message) (*messages.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
+	defer cancel()
 
-	receiveChannel := make(chan *[]byte, 100)
-	subscription, bindError := n.EncodedConn.BindRecvChan(newResponseChannel, receiveChannel)
-	if bindError != nil {
-		return nil, bindError
+	var response *messages.Message
+
+	err := n.exchange(ctx, channel, message, 0, func(received *messages.Message) bool {
+		response = received
+		return true
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	defer func() {
-		unsubscribeError := subscription.Unsubscribe()
-		if unsubscribeError != nil {
-			logrus.Error(unsubscribeError)
-		}
-	}()
+	return response, nil
+}
+
+// SendAwaitResponseMultiSingle sends the message and collects responses until one is
+// not marked as multi, waiting up to five seconds between responses
+func (n *Nats) SendAwaitResponseMultiSingle(channel string, message *messages.Message) ([]*messages.Message, error) {
+	results := make([]*messages.Message, 0)
 
-	message.RespChan = newResponseChannel
+	err := n.exchange(context.Background(), channel, message, defaultRequestTimeout, func(received *messages.Message) bool {
+		results = append(results, received)
+		return !received.Multi
+	})
 
-	publishError := n.EncodedConn.Publish(channel, message.Pack())
-	if publishError != nil {
-		return nil, publishError
+	if err == errIdle {
+		return nil, &TimeoutError{Channel: channel, Err: context.DeadlineExceeded}
 	}
 
-	results := make([]*messages.Message, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		select {
-		case receiveData := <-receiveChannel:
-			unpackMessage := new(messages.Message)
-			unpackError := unpackMessage.Unpack(*receiveData)
-			if unpackError != nil {
-				return nil, unpackError
-			}
+	return results, nil
+}
+
+// SendAwaitResponseMulti sends the message and collects every response until none
+// has been received for five seconds
+func (n *Nats) SendAwaitResponseMulti(channel string, message *messages.Message) ([]*messages.Message, error) {
+	results := make([]*messages.Message, 0)
 
-			results = append(results, unpackMessage)
+	err := n.exchange(context.Background(), channel, message, defaultRequestTimeout, func(received *messages.Message) bool {
+		results = append(results, received)
+		return false
+	})
 
-		case <-time.After(5 * time.Second):
-			return results, nil
-		}
+	if err != nil && err != errIdle {
+		return nil, err
 	}
+
+	return results, nil
 }
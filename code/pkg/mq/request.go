@@ -0,0 +1,291 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/pkg/consts"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var errIdle = errors.New("no response received within the idle timeout")
+
+// responseBuffer is the number of responses queued for a request that is slow to read
+// them, further responses are dropped so one request can not stall the shared inbox
+const responseBuffer = 64
+
+type pendingRequest struct {
+	responses chan []byte
+}
+
+// responseInbox is a single wildcard subscription shared by every request of a
+// connection, responses are routed to the waiting request by the last subject token
+type responseInbox struct {
+	prefix       string
+	subscription *nats.Subscription
+
+	mutex   sync.Mutex
+	counter uint64
+	dropped uint64
+	pending map[string]*pendingRequest
+}
+
+func (inbox *responseInbox) register() (string, *pendingRequest) {
+	inbox.mutex.Lock()
+	defer inbox.mutex.Unlock()
+
+	inbox.counter = inbox.counter + 1
+	token := strconv.FormatUint(inbox.counter, 10)
+
+	pending := &pendingRequest{
+		responses: make(chan []byte, responseBuffer),
+	}
+
+	inbox.pending[token] = pending
+
+	return inbox.prefix + token, pending
+}
+
+func (inbox *responseInbox) unregister(respChan string) {
+	inbox.mutex.Lock()
+	defer inbox.mutex.Unlock()
+
+	delete(inbox.pending, respChan[len(inbox.prefix):])
+}
+
+func (inbox *responseInbox) deliver(msg *nats.Msg) {
+	inbox.mutex.Lock()
+	defer inbox.mutex.Unlock()
+
+	pending, ok := inbox.pending[msg.Subject[len(inbox.prefix):]]
+
+	if !ok {
+		logrus.Debugf("Dropping response for finished request on %s", msg.Subject)
+		return
+	}
+
+	select {
+	case pending.responses <- msg.Data:
+	default:
+		inbox.dropped = inbox.dropped + 1
+		logrus.Warnf("Dropping response on %s, the request is not reading its responses", msg.Subject)
+	}
+}
+
+// DroppedResponses returns the number of responses dropped because the request they
+// belong to had a full response buffer
+func (n *Nats) DroppedResponses() uint64 {
+	n.inboxMutex.Lock()
+	inbox := n.inbox
+	n.inboxMutex.Unlock()
+
+	if inbox == nil {
+		return 0
+	}
+
+	inbox.mutex.Lock()
+	defer inbox.mutex.Unlock()
+
+	return inbox.dropped
+}
+
+func (n *Nats) responseInbox() (*responseInbox, error) {
+	n.inboxMutex.Lock()
+	defer n.inboxMutex.Unlock()
+
+	if n.inbox != nil {
+		return n.inbox, nil
+	}
+
+	if n.Conn == nil {
+		return nil, fmt.Errorf("not connected to nats")
+	}
+
+	inbox := &responseInbox{
+		prefix:  consts.MQNodePrefix + uuid.NewV4().String() + ".",
+		pending: map[string]*pendingRequest{},
+	}
+
+	subscription, err := n.Conn.Subscribe(inbox.prefix+"*", inbox.deliver)
+	if err != nil {
+		return nil, err
+	}
+
+	inbox.subscription = subscription
+	n.inbox = inbox
+
+	return inbox, nil
+}
+
+// exchange publishes the message with a response channel on the shared inbox and passes
+// every response to receive until it returns true. When idle is set the exchange also
+// ends with errIdle if no response arrives within that duration
+func (n *Nats) exchange(ctx context.Context, channel string, message *messages.Message, idle time.Duration,
+	receive func(*messages.Message) bool) error {
+
+	inbox, err := n.responseInbox()
+	if err != nil {
+		return err
+	}
+
+	respChan, pending := inbox.register()
+	defer inbox.unregister(respChan)
+
+	message.RespChan = respChan
+
+	publishError := n.PublishMessage(channel, message)
+	if publishError != nil {
+		return publishError
+	}
+
+	var idleTimer *time.Timer
+	var idleTimeout <-chan time.Time
+
+	if idle > 0 {
+		idleTimer = time.NewTimer(idle)
+		defer idleTimer.Stop()
+
+		idleTimeout = idleTimer.C
+	}
+
+	for {
+		select {
+		case data := <-pending.responses:
+			response := new(messages.Message)
+
+			if unpackError := response.Unpack(data); unpackError != nil {
+				return &DecodeError{Channel: channel, Err: unpackError}
+			}
+
+			if receive(response) {
+				return nil
+			}
+
+			if idleTimer != nil {
+				if !idleTimer.Stop() {
+					<-idleTimer.C
+				}
+
+				idleTimer.Reset(idle)
+			}
+
+		case <-idleTimeout:
+			return errIdle
+
+		case <-ctx.Done():
+			return &TimeoutError{Channel: channel, Err: ctx.Err()}
+		}
+	}
+}
+
+// Request sends the request payload on the channel and unpacks the reply into the
+// response payload. A failure result sent by the responder is returned as a RemoteError
+func (n *Nats) Request(ctx context.Context, channel string, request interface{}, response messages.Payload) error {
+	message, err := buildRequest(request)
+	if err != nil {
+		return err
+	}
+
+	var reply *messages.Message
+
+	err = n.exchange(ctx, channel, message, 0, func(received *messages.Message) bool {
+		reply = received
+		return true
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return decodeResponse(channel, reply, response)
+}
+
+// RequestStream sends the request payload on the channel and calls handle for every
+// reply until the responder sends a reply that is not marked as multi
+func (n *Nats) RequestStream(ctx context.Context, channel string, request interface{}, handle func(*messages.Message) error) error {
+	message, err := buildRequest(request)
+	if err != nil {
+		return err
+	}
+
+	var handleError error
+
+	err = n.exchange(ctx, channel, message, 0, func(received *messages.Message) bool {
+		handleError = handle(received)
+		return handleError != nil || !received.Multi
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return handleError
+}
+
+// Gather sends the request payload on a channel with several responders and calls handle
+// for every reply until none has been received for the idle duration
+func (n *Nats) Gather(ctx context.Context, channel string, request interface{}, idle time.Duration,
+	handle func(*messages.Message) error) error {
+
+	message, err := buildRequest(request)
+	if err != nil {
+		return err
+	}
+
+	var handleError error
+
+	err = n.exchange(ctx, channel, message, idle, func(received *messages.Message) bool {
+		handleError = handle(received)
+		return handleError != nil
+	})
+
+	if err != nil && err != errIdle {
+		return err
+	}
+
+	return handleError
+}
+
+func buildRequest(request interface{}) (*messages.Message, error) {
+	return new(messages.Message).Request("").BuildM(request)
+}
+
+func decodeResponse(channel string, reply *messages.Message, response messages.Payload) error {
+	expectedType, err := messages.TypeOf(response)
+	if err != nil {
+		return err
+	}
+
+	if reply.Type == messages.ResultMessage && expectedType != messages.ResultMessage {
+		result := new(messages.Result)
+
+		if unpackError := result.Unpack(reply.Data); unpackError != nil {
+			return &DecodeError{Channel: channel, Type: reply.Type, Err: unpackError}
+		}
+
+		if result.Type == messages.FailureResult {
+			return &RemoteError{Channel: channel, Result: *result}
+		}
+	}
+
+	if reply.Type != expectedType {
+		return &DecodeError{Channel: channel, Type: reply.Type, Err: fmt.Errorf("expected message type %d", expectedType)}
+	}
+
+	if unpackError := response.Unpack(reply.Data); unpackError != nil {
+		return &DecodeError{Channel: channel, Type: reply.Type, Err: unpackError}
+	}
+
+	if result, ok := response.(*messages.Result); ok && result.Type == messages.FailureResult {
+		return &RemoteError{Channel: channel, Result: *result}
+	}
+
+	return nil
+}
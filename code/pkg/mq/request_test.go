@@ -0,0 +1,148 @@
+package mq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+// runService runs the service until the test ends
+func runService(t *testing.T, service *Service) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		service.Run(stop)
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+}
+
+func TestResponseInboxDropsWhenFull(t *testing.T) {
+	inbox := &responseInbox{prefix: "inbox.", pending: map[string]*pendingRequest{}}
+
+	respChan, pending := inbox.register()
+
+	delivered := make(chan struct{})
+
+	// Nobody reads the responses, delivering must still never block
+	go func() {
+		for i := 0; i < responseBuffer+3; i++ {
+			inbox.deliver(&nats.Msg{Subject: respChan, Data: []byte{byte(i)}})
+		}
+
+		inbox.deliver(&nats.Msg{Subject: "inbox.unknown", Data: []byte{0}})
+		close(delivered)
+	}()
+
+	select {
+	case <-delivered:
+	case <-time.After(time.Second):
+		t.Fatal("delivering to a request that does not read its responses blocked")
+	}
+
+	if len(pending.responses) != responseBuffer || inbox.dropped != 3 {
+		t.Fatalf("%d responses queued and %d dropped", len(pending.responses), inbox.dropped)
+	}
+
+	if first := <-pending.responses; first[0] != 0 {
+		t.Fatalf("first queued response is %d", first[0])
+	}
+
+	inbox.unregister(respChan)
+
+	if len(inbox.pending) != 0 {
+		t.Fatal("request still registered after unregister")
+	}
+}
+
+func TestSlowRequestDoesNotStallOtherRequests(t *testing.T) {
+	natsConfig := startServer(t)
+
+	responder := connect(t, natsConfig, "responder")
+
+	service := new(Service).Init(responder, 2)
+	service.Handle(messages.ResultMessage, func(request *Request, stream *Responder) (interface{}, error) {
+		if request.Channel == "test.stream" {
+			for i := 0; i < responseBuffer+10; i++ {
+				if err := stream.Send(new(messages.Result).Init(messages.SuccessResult, "part")); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return new(messages.Result).Init(messages.SuccessResult, "done"), nil
+	})
+
+	for _, channel := range []string{"test.stream", "test.echo"} {
+		if err := service.Subscribe(channel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runService(t, service)
+
+	client := connect(t, natsConfig, "client")
+
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	streamDone := make(chan struct{})
+
+	streamCtx, cancelStream := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelStream()
+
+	go func() {
+		defer close(streamDone)
+
+		first := true
+
+		// The stream is expected to lose responses and is cancelled so its error is ignored
+		_ = client.RequestStream(streamCtx, "test.stream", new(messages.Result).Init(messages.SuccessResult, ""),
+			func(*messages.Message) error {
+				if first {
+					first = false
+					close(blocked)
+					<-release
+				}
+
+				return nil
+			})
+	}()
+
+	<-blocked
+
+	// Wait until the responses of the stream have overflowed its buffer
+	deadline := time.Now().Add(2 * time.Second)
+
+	for client.DroppedResponses() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no responses of the stalled stream were dropped")
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result := new(messages.Result)
+
+	if err := client.Request(ctx, "test.echo", new(messages.Result).Init(messages.SuccessResult, ""), result); err != nil {
+		t.Fatalf("request behind a stalled stream failed: %s", err)
+	}
+
+	if result.Text != "done" {
+		t.Fatalf("unexpected reply %+v", result)
+	}
+
+	close(release)
+	cancelStream()
+	<-streamDone
+}
@@ -0,0 +1,89 @@
+package mq
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+// Server is an embedded nats server used to run every node in a single process
+type Server struct {
+	Config structs.NatsConfig
+	server *server.Server
+}
+
+func (s *Server) Init(cfg structs.NatsConfig) *Server {
+	*s = Server{
+		Config: cfg,
+	}
+
+	return s
+}
+
+// Start starts the embedded server on the host in the config and waits until it
+// is ready to accept connections. Only user, password and token authentication are
+// supported, a config with credentials, nkey or tls files is refused
+func (s *Server) Start() error {
+	if s.Config.CredsFile != "" || s.Config.NKeySeedFile != "" || s.Config.TLSCert != "" || s.Config.TLSKey != "" ||
+		s.Config.TLSCA != "" {
+		return fmt.Errorf("embedded nats server does not support credentials, nkey or tls settings, use an external server")
+	}
+
+	host, portString, splitError := net.SplitHostPort(s.Config.Host)
+	if splitError != nil {
+		return splitError
+	}
+
+	port, portError := strconv.Atoi(portString)
+	if portError != nil {
+		return portError
+	}
+
+	natsServer, serverError := server.NewServer(&server.Options{
+		Host:          host,
+		Port:          port,
+		Username:      s.Config.User,
+		Password:      s.Config.Pass,
+		Authorization: s.Config.Token,
+		NoSigs:        true,
+	})
+
+	if serverError != nil {
+		return serverError
+	}
+
+	go natsServer.Start()
+
+	if !natsServer.ReadyForConnections(5 * time.Second) {
+		natsServer.Shutdown()
+		return fmt.Errorf("embedded nats server did not become ready on %s", s.Config.Host)
+	}
+
+	s.server = natsServer
+
+	return nil
+}
+
+// Addr returns the address the server listens on, it resolves the port when the server
+// was started on a random port with port -1
+func (s *Server) Addr() string {
+	if s.server == nil {
+		return s.Config.Host
+	}
+
+	return s.server.Addr().String()
+}
+
+// Shutdown stops the embedded server
+func (s *Server) Shutdown() {
+	if s.server == nil {
+		return
+	}
+
+	s.server.Shutdown()
+	s.server.WaitForShutdown()
+}
@@ -0,0 +1,62 @@
+package mq
+
+import (
+	"testing"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/structs"
+)
+
+// startServer starts an embedded server on a random port and returns a config to reach it
+func startServer(t *testing.T) structs.NatsConfig {
+	server := new(Server).Init(structs.NatsConfig{Host: "127.0.0.1:-1"})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(server.Shutdown)
+
+	return structs.NatsConfig{Host: server.Addr()}
+}
+
+// connect opens a connection to the server that is closed when the test ends
+func connect(t *testing.T, natsConfig structs.NatsConfig, name string) *Nats {
+	natsConfig.Name = name
+
+	connection := new(Nats).Init(natsConfig)
+
+	if err := connection.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(connection.Conn.Close)
+
+	return connection
+}
+
+func TestServerStart(t *testing.T) {
+	connection := connect(t, startServer(t), "test")
+
+	if !connection.Connected() {
+		t.Fatal("not connected to the embedded server")
+	}
+}
+
+func TestServerRefusesUnsupportedAuthentication(t *testing.T) {
+	for _, natsConfig := range []structs.NatsConfig{
+		{CredsFile: "user.creds"},
+		{NKeySeedFile: "user.nk"},
+		{TLSCert: "cert.pem"},
+		{TLSKey: "key.pem"},
+		{TLSCA: "ca.pem"},
+	} {
+		natsConfig.Host = "127.0.0.1:-1"
+
+		server := new(Server).Init(natsConfig)
+
+		if err := server.Start(); err == nil {
+			server.Shutdown()
+			t.Fatalf("expected config %+v to be refused", natsConfig)
+		}
+	}
+}
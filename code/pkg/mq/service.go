@@ -0,0 +1,220 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+	"github.com/sirupsen/logrus"
+)
+
+// Request is a message received by a Service on one of its channels
+type Request struct {
+	Channel string
+	Message *messages.Message
+}
+
+// Unpack decodes the data of the request into the payload
+func (request *Request) Unpack(payload messages.Payload) error {
+	return payload.Unpack(request.Message.Data)
+}
+
+// Responder sends intermediate replies for a streaming request, every reply sent
+// through it is marked as multi so the requester keeps waiting for the final reply
+type Responder struct {
+	nats     *Nats
+	respChan string
+	sequence uint64
+}
+
+// Send publishes an intermediate reply, it does nothing when the request did not
+// ask for a response
+func (responder *Responder) Send(payload interface{}) error {
+	if responder.respChan == "" {
+		return nil
+	}
+
+	message, err := new(messages.Message).ResponseMulti(true).BuildM(payload)
+	if err != nil {
+		return err
+	}
+
+	return responder.publish(message)
+}
+
+func (responder *Responder) reply(payload interface{}) error {
+	if responder.respChan == "" {
+		return nil
+	}
+
+	message, err := new(messages.Message).Response().BuildM(payload)
+	if err != nil {
+		return err
+	}
+
+	return responder.publish(message)
+}
+
+// publish numbers the replies of the request separately so response channels do not
+// accumulate in the sequences of the connection
+func (responder *Responder) publish(message *messages.Message) error {
+	responder.sequence = responder.sequence + 1
+
+	return responder.nats.publishStamped(responder.respChan, message, responder.sequence)
+}
+
+// HandlerFunc handles a request. The returned payload is sent as the final reply, a nil
+// payload is replied to with a success result and an error with a failure result
+type HandlerFunc func(request *Request, responder *Responder) (interface{}, error)
+
+// Service receives messages on a set of channels and dispatches them to the handler
+// registered for their type, running at most concurrency handlers at once. Messages are
+// handled in the order they are received when concurrency is one
+type Service struct {
+	nats        *Nats
+	concurrency int
+	handlers    map[messages.MessageType]HandlerFunc
+	filters     map[messages.MessageType]*CommandFilter
+
+	receiveChannel chan *nats.Msg
+	subscriptions  []*nats.Subscription
+	running        sync.WaitGroup
+}
+
+func (service *Service) Init(n *Nats, concurrency int) *Service {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	*service = Service{
+		nats:           n,
+		concurrency:    concurrency,
+		handlers:       map[messages.MessageType]HandlerFunc{},
+		filters:        map[messages.MessageType]*CommandFilter{},
+		receiveChannel: make(chan *nats.Msg, 100),
+	}
+
+	return service
+}
+
+// Handle registers the handler for the message type
+func (service *Service) Handle(messageType messages.MessageType, handler HandlerFunc) {
+	service.handlers[messageType] = handler
+}
+
+// Filter drops messages of the given types that are rejected by the command filter,
+// the request is replied to with a failure result
+func (service *Service) Filter(commandFilter *CommandFilter, messageTypes ...messages.MessageType) {
+	for _, messageType := range messageTypes {
+		service.filters[messageType] = commandFilter
+	}
+}
+
+// Subscribe starts receiving messages on the channel, the connection must be established
+func (service *Service) Subscribe(channel string) error {
+	subscription, err := service.nats.Conn.ChanSubscribe(channel, service.receiveChannel)
+	if err != nil {
+		return err
+	}
+
+	service.subscriptions = append(service.subscriptions, subscription)
+
+	return nil
+}
+
+// Run dispatches received messages until the stop channel is closed. On stop the
+// subscriptions are removed and Run waits for the running handlers to finish
+func (service *Service) Run(stop <-chan struct{}) {
+	slots := make(chan struct{}, service.concurrency)
+
+	defer service.shutdown()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case msg := <-service.receiveChannel:
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+
+			service.running.Add(1)
+
+			go func() {
+				defer func() {
+					<-slots
+					service.running.Done()
+				}()
+
+				service.dispatch(msg)
+			}()
+		}
+	}
+}
+
+func (service *Service) shutdown() {
+	for _, subscription := range service.subscriptions {
+		if err := subscription.Unsubscribe(); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+			logrus.Error(err)
+		}
+	}
+
+	service.running.Wait()
+}
+
+func (service *Service) dispatch(msg *nats.Msg) {
+	requestMessage := new(messages.Message)
+
+	if err := requestMessage.Unpack(msg.Data); err != nil {
+		logrus.Errorf("failed to unpack message on %s: %s", msg.Subject, err)
+		return
+	}
+
+	logrus.Debugf("Received Message: %+v", requestMessage)
+
+	request := &Request{Channel: msg.Subject, Message: requestMessage}
+	responder := &Responder{nats: service.nats, respChan: requestMessage.RespChan}
+
+	var response interface{}
+	var err error
+
+	if commandFilter, ok := service.filters[requestMessage.Type]; ok {
+		err = commandFilter.Accept(msg.Subject, requestMessage)
+	}
+
+	if err != nil {
+		logrus.Warnf("dropping message: %s", err)
+		response = new(messages.Result).Init(messages.FailureResult, err.Error())
+	} else if response, err = service.call(request, responder); err != nil {
+		logrus.Errorf("failed to handle message of type %d on %s: %s", requestMessage.Type, msg.Subject, err)
+		response = new(messages.Result).Init(messages.FailureResult, err.Error())
+	} else if response == nil {
+		response = new(messages.Result).Init(messages.SuccessResult, "")
+	}
+
+	if replyError := responder.reply(response); replyError != nil {
+		logrus.Errorf("failed to reply on %s: %s", requestMessage.RespChan, replyError)
+	}
+}
+
+func (service *Service) call(request *Request, responder *Responder) (response interface{}, err error) {
+	handler, ok := service.handlers[request.Message.Type]
+	if !ok {
+		return nil, fmt.Errorf("no handler registered for message type %d", request.Message.Type)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logrus.Errorf("handler panic: %v\n%s", recovered, debug.Stack())
+			err = fmt.Errorf("handler panic: %v", recovered)
+		}
+	}()
+
+	return handler(request, responder)
+}
@@ -0,0 +1,323 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+// startService runs a service with the handlers on the test channel and returns a
+// client connected to the same server
+func startService(t *testing.T, concurrency int, setup func(*Service)) *Nats {
+	natsConfig := startServer(t)
+
+	service := new(Service).Init(connect(t, natsConfig, "service"), concurrency)
+	setup(service)
+
+	if err := service.Subscribe("test.service"); err != nil {
+		t.Fatal(err)
+	}
+
+	runService(t, service)
+
+	return connect(t, natsConfig, "client")
+}
+
+func request(client *Nats, payload interface{}, response messages.Payload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	return client.Request(ctx, "test.service", payload, response)
+}
+
+func textResult(text string) *messages.Result {
+	return new(messages.Result).Init(messages.SuccessResult, text)
+}
+
+// sendStamped publishes a message with the given envelope metadata and returns the result
+// the service replied with
+func sendStamped(t *testing.T, client *Nats, sequence uint64, timestamp int64) *messages.Result {
+	inbox := nats.NewInbox()
+
+	subscription, err := client.Conn.SubscribeSync(inbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = subscription.Unsubscribe()
+	}()
+
+	message, err := new(messages.Message).Request(inbox).BuildM(textResult(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message.Stamp("sender", sequence)
+	message.Timestamp = timestamp
+
+	if err := client.Conn.Publish("test.service", message.Pack()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := subscription.NextMsg(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := new(messages.Message)
+	if err := reply.Unpack(msg.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	result := new(messages.Result)
+	if err := result.Unpack(reply.Data); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func expectRemoteError(t *testing.T, err error, text string) {
+	t.Helper()
+
+	var remoteError *RemoteError
+	if !errors.As(err, &remoteError) {
+		t.Fatalf("expected a remote error, got %v", err)
+	}
+
+	if text != "" && remoteError.Result.Text != text {
+		t.Fatalf("remote error is %q, expected %q", remoteError.Result.Text, text)
+	}
+}
+
+func TestServiceDispatch(t *testing.T) {
+	client := startService(t, 1, func(service *Service) {
+		service.Handle(messages.ResultMessage, func(request *Request, responder *Responder) (interface{}, error) {
+			received := new(messages.Result)
+
+			if err := request.Unpack(received); err != nil {
+				return nil, err
+			}
+
+			switch received.Text {
+			case "echo":
+				return textResult("echo " + request.Channel), nil
+			case "nil":
+				return nil, nil
+			case "error":
+				return nil, fmt.Errorf("handler failed")
+			default:
+				panic("handler panicked")
+			}
+		})
+	})
+
+	result := new(messages.Result)
+
+	if err := request(client, textResult("echo"), result); err != nil || result.Text != "echo test.service" {
+		t.Fatalf("echo returned %+v, %v", result, err)
+	}
+
+	// A nil payload is replied to with an empty success result
+	result = new(messages.Result)
+
+	if err := request(client, textResult("nil"), result); err != nil || *result != *textResult("") {
+		t.Fatalf("nil payload returned %+v, %v", result, err)
+	}
+
+	expectRemoteError(t, request(client, textResult("error"), new(messages.Result)), "handler failed")
+	expectRemoteError(t, request(client, textResult("panic"), new(messages.Result)), "handler panic: handler panicked")
+
+	// The service keeps running after a handler panicked
+	if err := request(client, textResult("echo"), new(messages.Result)); err != nil {
+		t.Fatalf("request after a panic failed: %s", err)
+	}
+}
+
+func TestServiceMissingHandler(t *testing.T) {
+	client := startService(t, 1, func(service *Service) {})
+
+	err := request(client, new(messages.Pose), new(messages.Result))
+
+	expectRemoteError(t, err, fmt.Sprintf("no handler registered for message type %d", messages.PoseMessage))
+}
+
+func TestServiceFilter(t *testing.T) {
+	commandFilter := new(CommandFilter).Init(time.Second)
+
+	handled := make(chan string, 10)
+
+	client := startService(t, 1, func(service *Service) {
+		service.Handle(messages.ResultMessage, func(request *Request, responder *Responder) (interface{}, error) {
+			handled <- strconv.FormatUint(request.Message.Sequence, 10)
+			return nil, nil
+		})
+
+		service.Filter(commandFilter, messages.ResultMessage)
+	})
+
+	now := messages.Now()
+
+	if result := sendStamped(t, client, 1, now); result.Type != messages.SuccessResult {
+		t.Fatalf("fresh command was refused: %+v", result)
+	}
+
+	if result := sendStamped(t, client, 2, now-int64(2*time.Second)); result.Type != messages.FailureResult {
+		t.Fatalf("stale command was accepted: %+v", result)
+	}
+
+	if result := sendStamped(t, client, 1, now); result.Type != messages.FailureResult {
+		t.Fatalf("out of order command was accepted: %+v", result)
+	}
+
+	if result := sendStamped(t, client, 4, now+1); result.Type != messages.SuccessResult {
+		t.Fatalf("command after a gap was refused: %+v", result)
+	}
+
+	close(handled)
+
+	sequences := []string{}
+	for sequence := range handled {
+		sequences = append(sequences, sequence)
+	}
+
+	if fmt.Sprint(sequences) != "[1 4]" {
+		t.Fatalf("handled sequences %v, expected [1 4]", sequences)
+	}
+
+	if stats := commandFilter.Stats(); stats != (CommandFilterStats{Accepted: 2, Stale: 1, OutOfOrder: 1, Missed: 2}) {
+		t.Fatalf("filter stats are %+v", stats)
+	}
+}
+
+func TestServiceConcurrencyLimit(t *testing.T) {
+	const concurrency = 3
+
+	var mutex sync.Mutex
+	active := 0
+	maxActive := 0
+
+	client := startService(t, concurrency, func(service *Service) {
+		service.Handle(messages.ResultMessage, func(request *Request, responder *Responder) (interface{}, error) {
+			mutex.Lock()
+			active = active + 1
+			if active > maxActive {
+				maxActive = active
+			}
+			mutex.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mutex.Lock()
+			active = active - 1
+			mutex.Unlock()
+
+			return nil, nil
+		})
+	})
+
+	var requests sync.WaitGroup
+
+	for i := 0; i < 4*concurrency; i++ {
+		requests.Add(1)
+
+		go func() {
+			defer requests.Done()
+
+			if err := request(client, textResult(""), new(messages.Result)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	requests.Wait()
+
+	if maxActive != concurrency {
+		t.Fatalf("%d handlers ran at once, expected %d", maxActive, concurrency)
+	}
+}
+
+func TestServiceKeepsOrderWithoutConcurrency(t *testing.T) {
+	const count = 50
+
+	received := make(chan string, count)
+
+	client := startService(t, 1, func(service *Service) {
+		service.Handle(messages.ResultMessage, func(request *Request, responder *Responder) (interface{}, error) {
+			result := new(messages.Result)
+
+			if err := request.Unpack(result); err != nil {
+				return nil, err
+			}
+
+			received <- result.Text
+
+			return nil, nil
+		})
+	})
+
+	for i := 0; i < count; i++ {
+		if err := client.Publish("test.service", textResult(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		select {
+		case text := <-received:
+			if text != strconv.Itoa(i) {
+				t.Fatalf("message %s handled at position %d", text, i)
+			}
+
+		case <-time.After(2 * time.Second):
+			t.Fatalf("only %d of %d messages were handled", i, count)
+		}
+	}
+}
+
+func TestServiceResponderStreams(t *testing.T) {
+	client := startService(t, 1, func(service *Service) {
+		service.Handle(messages.ResultMessage, func(request *Request, responder *Responder) (interface{}, error) {
+			for i := 0; i < 3; i++ {
+				if err := responder.Send(textResult(strconv.Itoa(i))); err != nil {
+					return nil, err
+				}
+			}
+
+			return textResult("done"), nil
+		})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	texts := []string{}
+
+	err := client.RequestStream(ctx, "test.service", textResult(""), func(message *messages.Message) error {
+		result := new(messages.Result)
+
+		if err := result.Unpack(message.Data); err != nil {
+			return err
+		}
+
+		texts = append(texts, fmt.Sprintf("%s:%t", result.Text, message.Multi))
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(texts) != "[0:true 1:true 2:true done:false]" {
+		t.Fatalf("stream replies were %v", texts)
+	}
+}
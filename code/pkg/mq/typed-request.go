@@ -0,0 +1,85 @@
+package mq
+
+import (
+	"context"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+// The typed requests wrap Request and Gather for every reply type so callers get the
+// decoded reply back instead of passing in a payload to unpack into
+
+// RequestResult sends the request and returns the result the responder replied with. A
+// failure result is returned as a RemoteError
+func (n *Nats) RequestResult(ctx context.Context, channel string, request interface{}) (*messages.Result, error) {
+	result := new(messages.Result)
+
+	if err := n.Request(ctx, channel, request, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RequestJointState sends the request and returns the joint state the responder replied with
+func (n *Nats) RequestJointState(ctx context.Context, channel string, request interface{}) (*messages.JointState, error) {
+	jointState := new(messages.JointState)
+
+	if err := n.Request(ctx, channel, request, jointState); err != nil {
+		return nil, err
+	}
+
+	return jointState, nil
+}
+
+// RequestPose sends the request and returns the pose the responder replied with
+func (n *Nats) RequestPose(ctx context.Context, channel string, request interface{}) (*messages.Pose, error) {
+	pose := new(messages.Pose)
+
+	if err := n.Request(ctx, channel, request, pose); err != nil {
+		return nil, err
+	}
+
+	return pose, nil
+}
+
+// RequestRobotState sends the request and returns the robot state the responder replied with
+func (n *Nats) RequestRobotState(ctx context.Context, channel string, request interface{}) (*messages.RobotState, error) {
+	robotState := new(messages.RobotState)
+
+	if err := n.Request(ctx, channel, request, robotState); err != nil {
+		return nil, err
+	}
+
+	return robotState, nil
+}
+
+// RequestVelocities sends the request and returns the velocities the responder replied with
+func (n *Nats) RequestVelocities(ctx context.Context, channel string, request interface{}) (*messages.Velocities, error) {
+	velocities := new(messages.Velocities)
+
+	if err := n.Request(ctx, channel, request, velocities); err != nil {
+		return nil, err
+	}
+
+	return velocities, nil
+}
+
+// GatherEStopStatus sends the request to every responder on the channel and calls handle
+// with the sender and the emergency stop status of every reply until none has been
+// received for the idle duration. A reply that is a failure result or can not be decoded
+// is passed to handle as a RemoteError or DecodeError with a nil status
+func (n *Nats) GatherEStopStatus(ctx context.Context, channel string, request interface{}, idle time.Duration,
+	handle func(sender string, status *messages.EStopStatus, err error) error) error {
+
+	return n.Gather(ctx, channel, request, idle, func(message *messages.Message) error {
+		status := new(messages.EStopStatus)
+
+		if err := decodeResponse(channel, message, status); err != nil {
+			return handle(message.Sender, nil, err)
+		}
+
+		return handle(message.Sender, status, nil)
+	})
+}
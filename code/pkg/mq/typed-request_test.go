@@ -0,0 +1,124 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/r4stl1n/micro-hal/code/pkg/hmath"
+	"github.com/r4stl1n/micro-hal/code/pkg/messages"
+)
+
+func TestTypedRequestsDecodeTheReply(t *testing.T) {
+	jointState := new(messages.JointState)
+	jointState.Joints.LeftFront = hmath.Vec3{0.1, 0.2, 0.3}
+	jointState.Servos = []messages.ServoState{{Alias: "front-left-leg", PinId: 13, Angle: 60}}
+
+	client := startService(t, 1, func(service *Service) {
+		service.Handle(messages.QueryMessage, func(_ *Request, _ *Responder) (interface{}, error) {
+			return jointState, nil
+		})
+
+		service.Handle(messages.StateRequestMessage, func(_ *Request, _ *Responder) (interface{}, error) {
+			return nil, nil
+		})
+
+		service.Handle(messages.PoseMessage, func(_ *Request, _ *Responder) (interface{}, error) {
+			return nil, errors.New("pose refused")
+		})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	received, err := client.RequestJointState(ctx, "test.service", new(messages.Query).Init())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.Joints != jointState.Joints || len(received.Servos) != 1 || received.Servos[0] != jointState.Servos[0] {
+		t.Fatalf("received joint state %+v, expected %+v", received, jointState)
+	}
+
+	result, err := client.RequestResult(ctx, "test.service", new(messages.StateRequest).Init("standing"))
+	if err != nil || result.Type != messages.SuccessResult {
+		t.Fatalf("expected a success result, got %+v and %v", result, err)
+	}
+
+	var remoteError *RemoteError
+	if result, err := client.RequestResult(ctx, "test.service", new(messages.Pose).Init()); !errors.As(err, &remoteError) ||
+		result != nil || remoteError.Result.Text != "pose refused" {
+		t.Fatalf("expected the refusal as a remote error, got %+v and %v", result, err)
+	}
+
+	var decodeError *DecodeError
+	if pose, err := client.RequestPose(ctx, "test.service", new(messages.Query).Init()); !errors.As(err, &decodeError) || pose != nil {
+		t.Fatalf("expected a decode error for a joint state reply, got %+v and %v", pose, err)
+	}
+}
+
+func TestGatherEStopStatus(t *testing.T) {
+	natsConfig := startServer(t)
+
+	handlers := map[string]HandlerFunc{
+		"engaged": func(_ *Request, _ *Responder) (interface{}, error) {
+			status := new(messages.EStopStatus)
+			status.Node = "engaged"
+			status.Engaged = true
+			status.Reason = "test"
+
+			return status, nil
+		},
+		"refusing": func(_ *Request, _ *Responder) (interface{}, error) {
+			return nil, errors.New("wrong token")
+		},
+	}
+
+	for name, handler := range handlers {
+		service := new(Service).Init(connect(t, natsConfig, name), 1)
+		service.Handle(messages.QueryMessage, handler)
+
+		if err := service.Subscribe("test.estop"); err != nil {
+			t.Fatal(err)
+		}
+
+		runService(t, service)
+	}
+
+	client := connect(t, natsConfig, "client")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var replies []string
+
+	err := client.GatherEStopStatus(ctx, "test.estop", new(messages.Query).Init(), 200*time.Millisecond,
+		func(sender string, status *messages.EStopStatus, err error) error {
+			var remoteError *RemoteError
+
+			switch {
+			case status != nil && err == nil:
+				replies = append(replies, sender+":"+status.Reason)
+
+			case status == nil && errors.As(err, &remoteError):
+				replies = append(replies, sender+":"+remoteError.Result.Text)
+
+			default:
+				t.Errorf("unexpected reply from %s: %+v and %v", sender, status, err)
+			}
+
+			return nil
+		})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(replies)
+
+	if len(replies) != 2 || replies[0] != "engaged:test" || replies[1] != "refusing:wrong token" {
+		t.Fatalf("gathered replies %v", replies)
+	}
+}
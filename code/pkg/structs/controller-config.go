@@ -0,0 +1,65 @@
+package structs
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+type ControllerConfig struct {
+	LoopRate      float32       // control loop rate in Hz
+	CommandMaxAge time.Duration // pose and velocity commands older than this are dropped, zero disables the check. Needs synced clocks
+
+	CommandTimeout time.Duration // walking stops when no velocity command arrives for this long, zero disables the watchdog
+	StopRampTime   time.Duration // time taken to ramp the velocity to zero and settle to the nominal stance
+	TransitionTime time.Duration // time taken by the stand up, sit down and lie down transitions
+
+	EStopResetToken string // token required to release the emergency stop, resets are refused when empty
+
+	URDFPath       string // urdf used for the robot geometry, the built in geometry is used when empty
+	URDFJointNames string // json document or path of a json file mapping the urdf joints onto the legs, the built in names are used when empty
+}
+
+func (c *ControllerConfig) Defaults() *ControllerConfig {
+
+	*c = ControllerConfig{
+		LoopRate:       100.0,
+		CommandTimeout: 500 * time.Millisecond,
+		StopRampTime:   500 * time.Millisecond,
+		TransitionTime: 1500 * time.Millisecond,
+	}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("CONTROLLER_LOOP_RATE"), 32); err == nil && rate > 0 {
+		c.LoopRate = float32(rate)
+	}
+
+	if maxAge, err := time.ParseDuration(os.Getenv("CONTROLLER_COMMAND_MAX_AGE")); err == nil && maxAge >= 0 {
+		c.CommandMaxAge = maxAge
+	}
+
+	if timeout, err := time.ParseDuration(os.Getenv("CONTROLLER_COMMAND_TIMEOUT")); err == nil && timeout >= 0 {
+		c.CommandTimeout = timeout
+	}
+
+	if rampTime, err := time.ParseDuration(os.Getenv("CONTROLLER_STOP_RAMP_TIME")); err == nil && rampTime >= 0 {
+		c.StopRampTime = rampTime
+	}
+
+	if transitionTime, err := time.ParseDuration(os.Getenv("CONTROLLER_TRANSITION_TIME")); err == nil && transitionTime >= 0 {
+		c.TransitionTime = transitionTime
+	}
+
+	if os.Getenv("ESTOP_RESET_TOKEN") != "" {
+		c.EStopResetToken = os.Getenv("ESTOP_RESET_TOKEN")
+	}
+
+	if os.Getenv("CONTROLLER_URDF") != "" {
+		c.URDFPath = os.Getenv("CONTROLLER_URDF")
+	}
+
+	if os.Getenv("CONTROLLER_URDF_JOINT_NAMES") != "" {
+		c.URDFJointNames = os.Getenv("CONTROLLER_URDF_JOINT_NAMES")
+	}
+
+	return c
+}
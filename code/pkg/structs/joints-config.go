@@ -0,0 +1,113 @@
+package structs
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	JointsBackendHardware = "hardware"
+	JointsBackendSim      = "sim"
+)
+
+type JointsConfig struct {
+	Backend      string
+	I2CDevice    string
+	ServoMapPath string
+	StatePath    string // servo angles saved on shutdown and used as the start of the startup move
+
+	CommandMaxAge time.Duration // joint commands older than this are dropped, zero disables the check. Needs synced clocks
+	TelemetryRate float32       // joint state publish rate in Hz, zero disables the stream
+
+	EStopResetToken string // token required to release the emergency stop, resets are refused when empty
+
+	IgnoreSoftLimits bool // drive the servos past the soft limits and joint constraints of the servo map
+
+	// The motion settings only shape the startup move, joint commands from the controller
+	// follow its own trajectories and are written to the servos as they arrive
+	MotionProfile         string  // linear, trapezoidal or minimum-jerk easing of the startup move
+	MotionMaxVelocity     float32 // degrees per second of the startup move
+	MotionMaxAcceleration float32 // degrees per second squared of the startup move
+	MotionRate            float32 // servo motion step rate in Hz
+
+	SimSlewRate    float32 // simulated servo speed in degrees per second
+	SimPublishRate float32 // simulated joint state publish rate in Hz
+}
+
+func (c *JointsConfig) Defaults() *JointsConfig {
+
+	*c = JointsConfig{
+		Backend:       JointsBackendHardware,
+		I2CDevice:     "/dev/i2c-1",
+		ServoMapPath:  "./ServoMap.json",
+		StatePath:     "./ServoState.json",
+		TelemetryRate: 20.0,
+
+		MotionProfile:         "minimum-jerk",
+		MotionMaxVelocity:     90.0,
+		MotionMaxAcceleration: 360.0,
+		MotionRate:            50.0,
+
+		SimSlewRate:    350.0,
+		SimPublishRate: 50.0,
+	}
+
+	if os.Getenv("JOINTS_BACKEND") != "" {
+		c.Backend = os.Getenv("JOINTS_BACKEND")
+	}
+
+	if os.Getenv("JOINTS_I2C_DEVICE") != "" {
+		c.I2CDevice = os.Getenv("JOINTS_I2C_DEVICE")
+	}
+
+	if os.Getenv("JOINTS_SERVO_MAP") != "" {
+		c.ServoMapPath = os.Getenv("JOINTS_SERVO_MAP")
+	}
+
+	if os.Getenv("JOINTS_STATE_PATH") != "" {
+		c.StatePath = os.Getenv("JOINTS_STATE_PATH")
+	}
+
+	if maxAge, err := time.ParseDuration(os.Getenv("JOINTS_COMMAND_MAX_AGE")); err == nil && maxAge >= 0 {
+		c.CommandMaxAge = maxAge
+	}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("JOINTS_TELEMETRY_RATE"), 32); err == nil && rate >= 0 {
+		c.TelemetryRate = float32(rate)
+	}
+
+	if os.Getenv("ESTOP_RESET_TOKEN") != "" {
+		c.EStopResetToken = os.Getenv("ESTOP_RESET_TOKEN")
+	}
+
+	if ignore, err := strconv.ParseBool(os.Getenv("JOINTS_IGNORE_SOFT_LIMITS")); err == nil {
+		c.IgnoreSoftLimits = ignore
+	}
+
+	if os.Getenv("JOINTS_MOTION_PROFILE") != "" {
+		c.MotionProfile = os.Getenv("JOINTS_MOTION_PROFILE")
+	}
+
+	if velocity, err := strconv.ParseFloat(os.Getenv("JOINTS_MOTION_MAX_VELOCITY"), 32); err == nil && velocity >= 0 {
+		c.MotionMaxVelocity = float32(velocity)
+	}
+
+	if acceleration, err := strconv.ParseFloat(os.Getenv("JOINTS_MOTION_MAX_ACCELERATION"), 32); err == nil && acceleration >= 0 {
+		c.MotionMaxAcceleration = float32(acceleration)
+	}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("JOINTS_MOTION_RATE"), 32); err == nil && rate > 0 {
+		c.MotionRate = float32(rate)
+	}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("JOINTS_SIM_SLEW_RATE"), 32); err == nil && rate > 0 {
+		c.SimSlewRate = float32(rate)
+	}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("JOINTS_SIM_PUBLISH_RATE"), 32); err == nil && rate > 0 {
+		c.SimPublishRate = float32(rate)
+	}
+
+	return c
+}
@@ -1,25 +1,128 @@
 package structs
 
-import "os"
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
 
+// NatsConfig describes how to reach and authenticate with the nats servers. Values are
+// read from the json file in NATS_CONFIG first and can then be overridden from the env
 type NatsConfig struct {
-	Host string
-	User string
-	Pass string
+	Host    string   `json:"host"`    // primary server, also the listen address of the embedded server
+	Servers []string `json:"servers"` // fallback servers tried when the primary server is unavailable
+
+	User  string `json:"user"`
+	Pass  string `json:"pass"`
+	Token string `json:"token"`
+
+	CredsFile    string `json:"credsFile"`    // user jwt and nkey seed in a .creds file
+	NKeySeedFile string `json:"nkeySeedFile"` // nkey seed used to sign the server nonce
+
+	TLSCert string `json:"tlsCert"` // client certificate, enables tls together with TLSKey
+	TLSKey  string `json:"tlsKey"`
+	TLSCA   string `json:"tlsCa"` // root ca used to verify the server
+
+	Name string `json:"name"` // node name sent as the sender of every message
+
+	MaxReconnects   int `json:"maxReconnects"`   // reconnect attempts before the connection is closed, negative retries forever
+	ReconnectBuffer int `json:"reconnectBuffer"` // bytes of publishes buffered while reconnecting, zero fails them instead
 }
 
-func (c *NatsConfig) Defaults() *NatsConfig {
+// Defaults reads the config from NATS_CONFIG and the env. A config file that can not be
+// loaded is returned as an error so a node never falls back to an unauthenticated connection
+func (c *NatsConfig) Defaults() (*NatsConfig, error) {
 
 	*c = NatsConfig{
-		User: "ruser",
-		Pass: "T0pS3cr3t",
+		Host:          "localhost:4222",
+		MaxReconnects: -1,
+	}
+
+	if os.Getenv("NATS_CONFIG") != "" {
+		if err := c.Load(os.Getenv("NATS_CONFIG")); err != nil {
+			return nil, fmt.Errorf("failed to load the nats config %s: %w", os.Getenv("NATS_CONFIG"), err)
+		}
+	}
+
+	envStrings := map[string]*string{
+		"NATS_SERVER":    &c.Host,
+		"NATS_USER":      &c.User,
+		"NATS_PASS":      &c.Pass,
+		"NATS_TOKEN":     &c.Token,
+		"NATS_CREDS":     &c.CredsFile,
+		"NATS_NKEY_SEED": &c.NKeySeedFile,
+		"NATS_TLS_CERT":  &c.TLSCert,
+		"NATS_TLS_KEY":   &c.TLSKey,
+		"NATS_TLS_CA":    &c.TLSCA,
+		"NATS_NODE_NAME": &c.Name,
+	}
+
+	for name, value := range envStrings {
+		if os.Getenv(name) != "" {
+			*value = os.Getenv(name)
+		}
+	}
+
+	if maxReconnects, err := strconv.Atoi(os.Getenv("NATS_MAX_RECONNECTS")); err == nil {
+		c.MaxReconnects = maxReconnects
+	}
+
+	if reconnectBuffer, err := strconv.Atoi(os.Getenv("NATS_RECONNECT_BUFFER")); err == nil && reconnectBuffer >= 0 {
+		c.ReconnectBuffer = reconnectBuffer
+	}
+
+	if os.Getenv("NATS_SERVERS") != "" {
+		c.Servers = strings.Split(os.Getenv("NATS_SERVERS"), ",")
 	}
 
-	if os.Getenv("NATS_SERVER") != "" {
-		c.Host = os.Getenv("NATS_SERVER")
-	} else {
-		c.Host = "localhost:4222"
+	return c, nil
+}
+
+// Load reads the config from a json file, fields missing from the file are left unchanged
+func (c *NatsConfig) Load(path string) error {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, c)
+}
+
+// ServerList returns the primary server followed by the fallback servers
+func (c NatsConfig) ServerList() []string {
+
+	servers := []string{}
+
+	for _, server := range append([]string{c.Host}, c.Servers...) {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+
+	return servers
+}
+
+// String describes the config without any of the credentials so it is safe to log
+func (c NatsConfig) String() string {
+
+	auth := "none"
+
+	switch {
+	case c.CredsFile != "":
+		auth = "creds"
+	case c.NKeySeedFile != "":
+		auth = "nkey"
+	case c.Token != "":
+		auth = "token"
+	case c.User != "":
+		auth = "user " + c.User
 	}
 
-	return c
+	return fmt.Sprintf("servers: %s, auth: %s, tls: %t, name: %s", strings.Join(c.ServerList(), ","), auth,
+		c.TLSCert != "" || c.TLSCA != "", c.Name)
 }
@@ -1,5 +1,14 @@
 package structs
 
+// ServoJointMapping stores how a kinematic joint angle maps onto a servo angle
+type ServoJointMapping struct {
+	Leg        string  // left-front, right-front, left-back or right-back
+	Joint      string  // hip, upper or lower
+	ZeroOffset float32 // servo angle in degrees when the joint is at 0 radians
+	Direction  int     // 1 or -1, 0 is treated as 1
+	GearRatio  float32 // servo degrees per joint degree, 0 is treated as 1
+}
+
 // ServoCalibrationItem stores servo calibration information
 type ServoCalibrationItem struct {
 	Alias           string
@@ -8,10 +17,30 @@ type ServoCalibrationItem struct {
 	MinPulse        float32
 	MaxPulse        float32
 	DefaultPosition int
+	MinAngle        float32 // lowest safe servo angle in degrees
+	MaxAngle        float32 // highest safe servo angle in degrees, 0 allows the full actuation range
+	Mapping         ServoJointMapping
+}
+
+// ConstraintPoint is the allowed range of a joint at one angle of the joint it depends on
+type ConstraintPoint struct {
+	Angle float32 // angle of the depends on joint in radians
+	Min   float32 // lowest allowed joint angle in radians
+	Max   float32 // highest allowed joint angle in radians
+}
+
+// JointConstraint limits a joint by the angle of another joint of the same leg, the range
+// is interpolated between the points and held past the first and last point
+type JointConstraint struct {
+	Leg       string // left-front, right-front, left-back or right-back, empty applies to every leg
+	Joint     string // constrained joint, hip, upper or lower
+	DependsOn string // joint whose angle selects the allowed range
+	Points    []ConstraintPoint
 }
 
 // Map of servo calibration information
 type ServoCalibrationMap struct {
-	Name   string
-	Servos []ServoCalibrationItem
+	Name        string
+	Servos      []ServoCalibrationItem
+	Constraints []JointConstraint
 }